@@ -1,8 +1,13 @@
 package main
 
 import (
+	"fmt"
+	"log"
+	"os"
+
 	"drones/internal/app/ds"
 	"drones/internal/app/dsn"
+	"drones/internal/app/role"
 
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
@@ -22,12 +27,122 @@ func main() {
 }
 
 func MigrateSchema(db *gorm.DB) {
-	err := db.AutoMigrate(&ds.User{})
-	err = db.AutoMigrate(&ds.Region{})
-	err = db.AutoMigrate(&ds.Flight{})
-	err = db.AutoMigrate(&ds.FlightToRegion{})
+	if err := preserveLegacyAllowedHours(db); err != nil {
+		panic(err)
+	}
 
+	err := db.AutoMigrate(
+		&ds.User{},
+		&ds.District{},
+		&ds.DistrictModerator{},
+		&ds.Region{},
+		&ds.FlightSeries{},
+		&ds.Flight{},
+		&ds.FlightToRegion{},
+		&ds.FlightStatusEvent{},
+		&ds.Notification{},
+		&ds.OutboxMessage{},
+		&ds.Restriction{},
+		&ds.RestrictionToRegion{},
+	)
 	if err != nil {
 		panic(err)
 	}
+
+	if err := migrateDistricts(db); err != nil {
+		panic(err)
+	}
+
+	if err := dropExtraDrafts(db); err != nil {
+		panic(err)
+	}
+
+	// у пользователя может быть только один черновик, на это рассчитывают GetDraftFlight и CloneFlight
+	err = db.Exec(fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS idx_flights_one_draft ON flights (user_refer) WHERE status = '%s'`, ds.Draft.String())).Error
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Раньше разрешённые часы хранились строкой в произвольном формате, который асинхронный сервис не фиксировал,
+// поэтому в интервалы они не переводятся: старую колонку сохраняем под другим именем для ручного разбора,
+// а AutoMigrate создаст на её месте JSON со списком интервалов. Чтобы заполненные значения не пропали молча,
+// миграция останавливается, пока их потерю не подтвердят через DROP_LEGACY_ALLOWED_HOURS=yes.
+func preserveLegacyAllowedHours(db *gorm.DB) error {
+	var dataType string
+	err := db.Raw(`SELECT data_type FROM information_schema.columns WHERE table_name = 'flights' AND column_name = 'allowed_hours'`).
+		Scan(&dataType).Error
+	if err != nil || dataType != "text" {
+		return err
+	}
+
+	var filled int64
+	err = db.Raw(`SELECT COUNT(*) FROM flights WHERE TRIM(COALESCE(allowed_hours, '')) <> ''`).Scan(&filled).Error
+	if err != nil {
+		return err
+	}
+
+	if filled > 0 {
+		if os.Getenv("DROP_LEGACY_ALLOWED_HOURS") != "yes" {
+			return fmt.Errorf("%d flights have free-form allowed_hours that can't be converted to intervals; "+
+				"set DROP_LEGACY_ALLOWED_HOURS=yes to keep them only in allowed_hours_legacy", filled)
+		}
+
+		log.Printf("allowed_hours of %d flights moved to allowed_hours_legacy and won't be shown", filled)
+	}
+
+	return db.Exec(`ALTER TABLE flights RENAME COLUMN allowed_hours TO allowed_hours_legacy`).Error
+}
+
+// Округ раньше был строкой в регионе. Заводим округ на каждое встреченное название, привязываем к нему регионы,
+// а старую колонку сохраняем под другим именем.
+func migrateDistricts(db *gorm.DB) error {
+	var columns int64
+	err := db.Raw(`SELECT COUNT(*) FROM information_schema.columns WHERE table_name = 'regions' AND column_name = 'district'`).
+		Scan(&columns).Error
+	if err != nil || columns == 0 {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`INSERT INTO districts (name)
+			SELECT DISTINCT TRIM(district) FROM regions WHERE TRIM(COALESCE(district, '')) <> ''
+			ON CONFLICT (name) DO NOTHING`).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Exec(`UPDATE regions SET district_refer = districts.id, version = regions.version + 1 FROM districts
+			WHERE districts.name = TRIM(regions.district) AND regions.district_refer IS NULL`).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Exec(`ALTER TABLE regions RENAME COLUMN district TO district_legacy`).Error
+	})
+}
+
+// До уникального индекса у пользователя могло накопиться несколько черновиков. Оставляем последний изменённый,
+// остальные удаляем от имени системы с записью в историю, иначе индекс не создастся.
+func dropExtraDrafts(db *gorm.DB) error {
+	extra := `SELECT id FROM (
+		SELECT id, ROW_NUMBER() OVER (PARTITION BY user_refer ORDER BY GREATEST(date_created, date_modified) DESC, id DESC) AS rank
+		FROM flights WHERE status = @draft AND user_refer IS NOT NULL
+	) drafts WHERE rank > 1`
+	args := map[string]interface{}{
+		"draft":   ds.Draft.String(),
+		"deleted": ds.Deleted.String(),
+		"system":  role.System,
+		"reason":  "У пользователя может быть только один черновик",
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`INSERT INTO flight_status_events (flight_refer, from_status, to_status, actor_role, reason, date_created)
+			SELECT id, @draft, @deleted, @system, @reason, NOW() FROM flights WHERE id IN (`+extra+`)`, args).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Exec(`UPDATE flights SET status = @deleted, version = version + 1 WHERE id IN (`+extra+`)`, args).Error
+	})
 }
@@ -16,8 +16,13 @@ type Config struct {
 	ServiceHost string
 	ServicePort int
 
-	JWT   JWTConfig
-	Redis RedisConfig
+	JWT        JWTConfig
+	Redis      RedisConfig
+	Scheduler  SchedulerConfig
+	Moderation ModerationConfig
+	Outbox     OutboxConfig
+	Callback   CallbackConfig
+	Hours      AllowedHoursConfig
 }
 
 type RedisConfig struct {
@@ -32,11 +37,45 @@ type RedisConfig struct {
 type JWTConfig struct {
 }
 
+type ModerationConfig struct {
+	ClaimTTL         time.Duration     // сколько заявка закреплена за модератором
+	RejectionReasons map[string]string // код причины отклонения -> описание
+}
+
+type AllowedHoursConfig struct {
+	Provider string // "service" - сервис drones-async, "local" - расчёт по правилам регионов
+	Timezone string // часовой пояс часов работы регионов
+}
+
+// CallbackConfig - проверка подписи обратных вызовов от сервиса расчёта разрешённых часов
+type CallbackConfig struct {
+	Secret  string        // общий секрет HMAC, берётся из окружения
+	MaxSkew time.Duration // насколько время запроса может отличаться от нашего
+}
+
+type OutboxConfig struct {
+	AllowedHoursURL string        // сервис расчёта разрешённых часов
+	PollInterval    time.Duration // как часто проверять очередь
+	BatchSize       int           // сколько сообщений забирать за раз
+	MaxAttempts     int           // после стольких неудач сообщение уходит в dead letter
+	InitialBackoff  time.Duration // пауза после первой неудачи, дальше удваивается
+	MaxBackoff      time.Duration
+	RequestTimeout  time.Duration
+}
+
+type SchedulerConfig struct {
+	Interval    time.Duration // как часто запускать фоновые задачи
+	LeaderTTL   time.Duration // сколько живёт лидерство экземпляра без продления
+	DraftMaxAge time.Duration // черновики старше этого удаляются
+}
+
 const (
 	envRedisHost = "REDIS_HOST"
 	envRedisPort = "REDIS_PORT"
 	envRedisUser = "REDIS_USER"
 	envRedisPass = "REDIS_PASSWORD"
+
+	envCallbackSecret = "CALLBACK_SECRET"
 )
 
 func NewConfig(ctx context.Context) (*Config, error) {
@@ -75,6 +114,11 @@ func NewConfig(ctx context.Context) (*Config, error) {
 	cfg.Redis.Password = os.Getenv(envRedisPass)
 	cfg.Redis.User = os.Getenv(envRedisUser)
 
+	cfg.Callback.Secret = os.Getenv(envCallbackSecret)
+	if cfg.Callback.Secret == "" {
+		return nil, fmt.Errorf("callback secret must be set in %s", envCallbackSecret)
+	}
+
 	log.Info("config parsed")
 
 	return cfg, nil
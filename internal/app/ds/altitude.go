@@ -0,0 +1,61 @@
+package ds
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidAltitude = errors.New("invalid altitude")
+
+// ValidateAltitudeBand проверяет диапазон высот полёта в метрах над землёй
+func ValidateAltitudeBand(minAltitude int, maxAltitude int) error {
+	if minAltitude < 0 || maxAltitude <= 0 || minAltitude > maxAltitude {
+		return fmt.Errorf("%w: altitude band must satisfy 0 <= min <= max and max > 0, got %d..%d", ErrInvalidAltitude, minAltitude, maxAltitude)
+	}
+
+	return nil
+}
+
+func ValidateCeiling(ceiling int) error {
+	if ceiling < 0 {
+		return fmt.Errorf("%w: region ceiling can't be negative", ErrInvalidAltitude)
+	}
+
+	return nil
+}
+
+// AltitudeError - заявка поднимается выше потолка региона или не указывает высоту там, где потолок есть
+type AltitudeError struct {
+	RegionID   uint
+	RegionName string
+	Ceiling    int
+	Requested  int // 0 - высота в заявке не указана
+}
+
+func (e *AltitudeError) Error() string {
+	if e.Requested == 0 {
+		return fmt.Sprintf("region %q has a %d m ceiling, flight altitude must be specified", e.RegionName, e.Ceiling)
+	}
+
+	return fmt.Sprintf("flight altitude %d m exceeds the %d m ceiling of region %q", e.Requested, e.Ceiling, e.RegionName)
+}
+
+// CheckCeilings сверяет верхнюю границу полёта с потолками регионов; регионы без потолка пропускаются
+func CheckCeilings(maxAltitude int, regions []Region) error {
+	for _, region := range regions {
+		if region.MaxAltitude == 0 {
+			continue
+		}
+
+		if maxAltitude == 0 || maxAltitude > region.MaxAltitude {
+			return &AltitudeError{
+				RegionID:   region.ID,
+				RegionName: region.Name,
+				Ceiling:    region.MaxAltitude,
+				Requested:  maxAltitude,
+			}
+		}
+	}
+
+	return nil
+}
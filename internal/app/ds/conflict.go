@@ -0,0 +1,89 @@
+package ds
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FlightConflict - чужая заявка, которая пересекается с нашей по региону и времени
+type FlightConflict struct {
+	FlightID    uint
+	Status      string
+	TakeoffDate time.Time `swaggertype:"primitive,string"`
+	ArrivalDate time.Time `swaggertype:"primitive,string"`
+	RegionID    uint
+	RegionName  string
+	Blocking    bool
+}
+
+type ConflictError struct {
+	Conflicts []FlightConflict
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("flight conflicts with %d flight(s) in blocking regions", len(e.Conflicts))
+}
+
+// BlockingConflicts отбирает конфликты с одобренными заявками в регионах, где пересечения запрещены.
+// Пересечение с заявкой на модерации не мешает: из двух таких модератор одобрит только одну.
+func BlockingConflicts(conflicts []FlightConflict) []FlightConflict {
+	blocking := []FlightConflict{}
+	for _, conflict := range conflicts {
+		if conflict.Blocking && (conflict.Status == Completed.String() || conflict.Status == InFlight.String()) {
+			blocking = append(blocking, conflict)
+		}
+	}
+
+	return blocking
+}
+
+// CapacityError - одобрение превысило бы допустимое число одновременных полётов над регионом
+type CapacityError struct {
+	RegionID   uint
+	RegionName string
+	Capacity   int
+	Occupied   int
+	From       time.Time `swaggertype:"primitive,string"`
+	To         time.Time `swaggertype:"primitive,string"`
+}
+
+func (e *CapacityError) Error() string {
+	return fmt.Sprintf("region %q allows %d simultaneous flight(s), %d already approved between %s and %s",
+		e.RegionName, e.Capacity, e.Occupied, e.From.Format(time.RFC3339), e.To.Format(time.RFC3339))
+}
+
+// FlightActionResult - итог действия над одним полётом при массовых операциях
+type FlightActionResult struct {
+	FlightID   uint
+	Status     string
+	Error      string           `json:",omitempty"`
+	StatusCode int              `json:",omitempty"` // HTTP-код, которым ответил бы запрос по одной заявке
+	Conflicts  []FlightConflict `json:",omitempty"`
+}
+
+func NewFlightActionResult(flight Flight, to FlightStatus, err error) FlightActionResult {
+	if err != nil {
+		return FlightActionResult{
+			FlightID: flight.ID,
+			Status:   flight.Status,
+			Error:    err.Error(),
+		}
+	}
+
+	return FlightActionResult{
+		FlightID: flight.ID,
+		Status:   to.String(),
+	}
+}
+
+// ClaimError - заявка закреплена за другим модератором
+type ClaimError struct {
+	FlightID int
+	Holder   uuid.UUID
+}
+
+func (e *ClaimError) Error() string {
+	return fmt.Sprintf("flight %d is claimed by moderator %s", e.FlightID, e.Holder)
+}
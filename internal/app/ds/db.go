@@ -4,56 +4,172 @@ import (
 	"encoding/json"
 	"time"
 
+	"drones/internal/app/role"
+
 	"github.com/google/uuid"
+	"gorm.io/datatypes"
 )
 
 type Region struct {
-	ID             uint `gorm:"primaryKey;AUTO_INCREMENT"`
-	District       string
-	Name           string `gorm:"type:varchar(50);unique;not null"`
-	Details        string `gorm:"type:text"`
-	Status         string `gorm:"not null"`
-	AreaKm         json.Number
-	Population     json.Number
-	HeadName       string `gorm:"type:varchar(250)"`
-	HeadEmail      string `gorm:"type:varchar(50)"`
-	HeadPhone      string `gorm:"type:varchar(50)"`
-	AverageHeightM json.Number
-	ImageName      string
+	ID                   uint   `gorm:"primaryKey;AUTO_INCREMENT"`
+	DistrictRefer        *uint  `gorm:"index"`
+	District             string `gorm:"-"` // название округа: в ответе проставляется по DistrictRefer, в запросе можно передать вместо него
+	Name                 string `gorm:"type:varchar(50);unique;not null"`
+	Details              string `gorm:"type:text"`
+	Status               string `gorm:"not null"`
+	AreaKm               json.Number
+	Population           json.Number
+	HeadName             string `gorm:"type:varchar(250)"`
+	HeadEmail            string `gorm:"type:varchar(50)"`
+	HeadPhone            string `gorm:"type:varchar(50)"`
+	AverageHeightM       json.Number
+	ImageName            string
+	BlockOnConflict      bool           `gorm:"not null;default:false"`
+	MaxConcurrentFlights int            `gorm:"not null;default:0"`
+	MaxAltitude          int            `gorm:"not null;default:0"` // потолок полётов над регионом, м над землёй; 0 - без ограничения
+	OpensAt              string         `gorm:"type:varchar(5)"`    // часы работы HH:MM по местному времени, пусто - круглосуточно
+	ClosesAt             string         `gorm:"type:varchar(5)"`
+	Boundary             datatypes.JSON `swaggertype:"object"` // GeoJSON Polygon или MultiPolygon; площадь, центр и рамка считаются по нему
+	CentroidLon          float64
+	CentroidLat          float64
+	BBoxMinLon           float64
+	BBoxMinLat           float64
+	BBoxMaxLon           float64
+	BBoxMaxLat           float64
+	Version              int `gorm:"not null;default:1"`
 }
 
 type Flight struct {
-	ID             uint       `gorm:"primaryKey;AUTO_INCREMENT"`
-	ModeratorRefer *uuid.UUID `gorm:"type:uuid"`
-	UserRefer      *uuid.UUID `gorm:"type:uuid;not null"`
-	Status         string     `gorm:"type:varchar(50)"`
-	DateCreated    time.Time  `gorm:"not null" swaggertype:"primitive,string"`
-	DateProcessed  time.Time  `swaggertype:"primitive,string"`
-	DateFinished   time.Time  `swaggertype:"primitive,string"`
-	Moderator      User       `gorm:"foreignKey:ModeratorRefer;references:UUID"`
-	User           User       `gorm:"foreignKey:UserRefer;references:UUID;not null"`
-	TakeoffDate    time.Time  `swaggertype:"primitive,string"`
-	ArrivalDate    time.Time  `swaggertype:"primitive,string"`
-	AllowedHours   string     `swaggertype:"primitive,string"`
+	ID               uint                              `gorm:"primaryKey;AUTO_INCREMENT"`
+	ModeratorRefer   *uuid.UUID                        `gorm:"type:uuid"`
+	UserRefer        *uuid.UUID                        `gorm:"type:uuid;not null"`
+	Status           string                            `gorm:"type:varchar(50)"`
+	DateCreated      time.Time                         `gorm:"not null" swaggertype:"primitive,string"`
+	DateProcessed    time.Time                         `swaggertype:"primitive,string"`
+	DateFinished     time.Time                         `swaggertype:"primitive,string"`
+	DateModified     time.Time                         `swaggertype:"primitive,string"` // последнее изменение полей или участков
+	Moderator        User                              `gorm:"foreignKey:ModeratorRefer;references:UUID"`
+	User             User                              `gorm:"foreignKey:UserRefer;references:UUID;not null"`
+	TakeoffDate      time.Time                         `swaggertype:"primitive,string"`
+	ArrivalDate      time.Time                         `swaggertype:"primitive,string"`
+	AllowedHours     datatypes.JSONSlice[TimeInterval] `gorm:"not null;default:'[]'" swaggertype:"array,object"`
+	SeriesRefer      *uint
+	ParentRefer      *uint          `gorm:"index"` // отклонённая заявка, новой редакцией которой является эта
+	RejectionCode    string         `gorm:"type:varchar(50)"`
+	RejectionReason  string         `gorm:"type:text"`
+	ModeratorComment string         `gorm:"type:text"`
+	ActualTakeoff    *time.Time     `swaggertype:"primitive,string"`
+	ActualLanding    *time.Time     `swaggertype:"primitive,string"`
+	Overrun          bool           `gorm:"not null;default:false"` // время прилёта прошло, а о посадке не сообщили
+	MinAltitude      int            `gorm:"not null;default:0"`     // планируемый диапазон высот, м над землёй; 0..0 - не указан
+	MaxAltitude      int            `gorm:"not null;default:0"`
+	Route            datatypes.JSON `swaggertype:"object"`      // GeoJSON LineString, если регионы определены по маршруту
+	CorridorWidth    float64        `gorm:"not null;default:0"` // ширина коридора вдоль Route, м
+	Version          int            `gorm:"not null;default:1"`
+}
+
+// FlightSeries - регулярная заявка, из которой по правилу RRULE порождаются отдельные полёты
+type FlightSeries struct {
+	ID          uint       `gorm:"primaryKey;AUTO_INCREMENT"`
+	UserRefer   *uuid.UUID `gorm:"type:uuid;not null"`
+	RRule       string     `gorm:"type:text;not null"`
+	ExDates     string     `gorm:"type:text"`
+	DateCreated time.Time  `gorm:"not null" swaggertype:"primitive,string"`
+	User        User       `gorm:"foreignKey:UserRefer;references:UUID" json:"-"`
 }
 
+// FlightToRegion - участок (leg) полёта над регионом. Участки идут в порядке Sequence;
+// если EntryDate/ExitDate не заданы, участок занимает регион на всё время полёта.
 type FlightToRegion struct {
-	ID          uint   `gorm:"primaryKey;AUTO_INCREMENT"`
-	FlightRefer int    `gorm:"not null"`
-	RegionRefer int    `gorm:"not null"`
-	Flight      Flight `gorm:"foreignKey:FlightRefer"`
-	Region      Region `gorm:"foreignKey:RegionRefer"`
+	ID          uint       `gorm:"primaryKey;AUTO_INCREMENT"`
+	FlightRefer int        `gorm:"not null"`
+	RegionRefer int        `gorm:"not null"`
+	Sequence    int        `gorm:"not null;default:0"`
+	EntryDate   *time.Time `swaggertype:"primitive,string"`
+	ExitDate    *time.Time `swaggertype:"primitive,string"`
+	Flight      Flight     `gorm:"foreignKey:FlightRefer"`
+	Region      Region     `gorm:"foreignKey:RegionRefer"`
 }
 
 type FlightNoUser struct {
-	ID            uint      `gorm:"primaryKey;AUTO_INCREMENT"`
-	Status        string    `gorm:"type:varchar(50)"`
-	DateCreated   time.Time `gorm:"not null" swaggertype:"primitive,string"`
-	DateProcessed time.Time `swaggertype:"primitive,string"`
-	DateFinished  time.Time `swaggertype:"primitive,string"`
-	TakeoffDate   time.Time `swaggertype:"primitive,string"`
-	ArrivalDate   time.Time `swaggertype:"primitive,string"`
-	Moderator     string
-	User          string
-	AllowedHours  string `swaggertype:"primitive,string"`
+	ID               uint      `gorm:"primaryKey;AUTO_INCREMENT"`
+	Status           string    `gorm:"type:varchar(50)"`
+	DateCreated      time.Time `gorm:"not null" swaggertype:"primitive,string"`
+	DateProcessed    time.Time `swaggertype:"primitive,string"`
+	DateFinished     time.Time `swaggertype:"primitive,string"`
+	TakeoffDate      time.Time `swaggertype:"primitive,string"`
+	ArrivalDate      time.Time `swaggertype:"primitive,string"`
+	Moderator        string
+	User             string
+	AllowedHours     []TimeInterval
+	HoursCovered     bool           // разрешённые часы покрывают всё окно полёта
+	UncoveredHours   []TimeInterval // что осталось непокрытым
+	SeriesID         *uint
+	ParentID         *uint
+	ClaimedBy        string // модератор, который сейчас рассматривает заявку
+	RejectionCode    string
+	RejectionReason  string
+	ModeratorComment string
+	ActualTakeoff    *time.Time `swaggertype:"primitive,string"`
+	ActualLanding    *time.Time `swaggertype:"primitive,string"`
+	Overrun          bool
+	MinAltitude      int
+	MaxAltitude      int
+	Route            datatypes.JSON `swaggertype:"object"`
+	CorridorWidth    float64
+	Version          int
+}
+
+// Notification - уведомление пользователю внутри сервиса, например модератору об отмене одобренной им заявки
+type Notification struct {
+	ID          uint       `gorm:"primaryKey;AUTO_INCREMENT"`
+	UserRefer   *uuid.UUID `gorm:"type:uuid;not null;index"`
+	FlightRefer *int
+	Message     string     `gorm:"type:text;not null"`
+	DateCreated time.Time  `gorm:"not null" swaggertype:"primitive,string"`
+	DateRead    *time.Time `swaggertype:"primitive,string"`
+	User        User       `gorm:"foreignKey:UserRefer;references:UUID" json:"-"`
+}
+
+// OutboxMessage - сообщение во внешний сервис. Пишется в той же транзакции, что и изменение,
+// которое его порождает, и отправляется диспетчером после коммита.
+type OutboxMessage struct {
+	ID            uint           `gorm:"primaryKey;AUTO_INCREMENT"`
+	Topic         string         `gorm:"type:varchar(50);not null"`
+	Payload       datatypes.JSON `gorm:"not null" swaggertype:"object"`
+	Status        string         `gorm:"type:varchar(20);not null;index:idx_outbox_pending,priority:1"`
+	Attempts      int            `gorm:"not null;default:0"`
+	NextAttemptAt time.Time      `gorm:"not null;index:idx_outbox_pending,priority:2" swaggertype:"primitive,string"`
+	LastError     string         `gorm:"type:text"`
+	DateCreated   time.Time      `gorm:"not null" swaggertype:"primitive,string"`
+	DateSent      *time.Time     `swaggertype:"primitive,string"`
+	LeaseToken    string         `gorm:"type:varchar(36)" json:"-"` // кто из диспетчеров сейчас отправляет сообщение
+}
+
+type FlightStatusEvent struct {
+	ID          uint       `gorm:"primaryKey;AUTO_INCREMENT"`
+	FlightRefer int        `gorm:"not null;index"`
+	FromStatus  string     `gorm:"type:varchar(50)"`
+	ToStatus    string     `gorm:"type:varchar(50);not null"`
+	ActorRefer  *uuid.UUID `gorm:"type:uuid"`
+	ActorRole   role.Role
+	Reason      string    `gorm:"type:text"`
+	DateCreated time.Time `gorm:"not null" swaggertype:"primitive,string"`
+	Flight      Flight    `gorm:"foreignKey:FlightRefer"`
+}
+
+type FlightStatusEventNoUser struct {
+	FromStatus  string
+	ToStatus    string
+	Actor       string
+	ActorRole   role.Role
+	Reason      string
+	DateCreated time.Time `swaggertype:"primitive,string"`
+}
+
+type FlightLeg struct {
+	Sequence  int
+	Region    string
+	EntryDate *time.Time `swaggertype:"primitive,string"`
+	ExitDate  *time.Time `swaggertype:"primitive,string"`
 }
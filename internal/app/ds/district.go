@@ -0,0 +1,37 @@
+package ds
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+var ErrUnknownDistrict = errors.New("unknown district")
+var ErrNotModerator = errors.New("user is not a moderator")
+var ErrDistrictExists = errors.New("district with this name already exists")
+var ErrNotDistrictModerator = errors.New("flight is handled by the moderators of its districts")
+
+// District - округ: группа регионов со своей границей и модераторами, которые отвечают за заявки над ним
+type District struct {
+	ID       uint           `gorm:"primaryKey;AUTO_INCREMENT"`
+	Name     string         `gorm:"type:varchar(100);unique;not null"`
+	Boundary datatypes.JSON `swaggertype:"object"` // GeoJSON Polygon или MultiPolygon
+}
+
+type DistrictModerator struct {
+	ID             uint       `gorm:"primaryKey;AUTO_INCREMENT"`
+	DistrictRefer  int        `gorm:"not null;uniqueIndex:idx_district_moderator"`
+	ModeratorRefer *uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_district_moderator"`
+	District       District   `gorm:"foreignKey:DistrictRefer"`
+	Moderator      User       `gorm:"foreignKey:ModeratorRefer;references:UUID"`
+}
+
+// DistrictSummary - строка списка округов
+type DistrictSummary struct {
+	ID             uint
+	Name           string
+	RegionCount    int      // действующие регионы округа
+	PendingFlights int      // заявки, ждущие модерации, хотя бы один участок которых проходит над округом
+	Moderators     []string `gorm:"-"`
+}
@@ -0,0 +1,212 @@
+package ds
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var ErrInvalidAllowedHours = errors.New("invalid allowed hours")
+
+// TimeInterval - полуоткрытый отрезок времени [From, To)
+type TimeInterval struct {
+	From time.Time `swaggertype:"primitive,string"`
+	To   time.Time `swaggertype:"primitive,string"`
+}
+
+// ValidateAllowedHours проверяет, что разрешённые часы не выходят за окно полёта
+func ValidateAllowedHours(intervals []TimeInterval, takeoff_date time.Time, arrival_date time.Time) error {
+	for i, interval := range intervals {
+		if !interval.From.Before(interval.To) {
+			return fmt.Errorf("%w: interval %d must end after it starts", ErrInvalidAllowedHours, i+1)
+		}
+		if interval.From.Before(takeoff_date) || interval.To.After(arrival_date) {
+			return fmt.Errorf("%w: interval %d is outside of the flight window %s - %s", ErrInvalidAllowedHours, i+1,
+				takeoff_date.Format(time.RFC3339), arrival_date.Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
+
+// UncoveredHours возвращает части окна полёта, которые разрешённые часы не покрывают
+func UncoveredHours(intervals []TimeInterval, takeoff_date time.Time, arrival_date time.Time) []TimeInterval {
+	sorted := append([]TimeInterval{}, intervals...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].From.Before(sorted[j].From)
+	})
+
+	uncovered := []TimeInterval{}
+	covered := takeoff_date
+	for _, interval := range sorted {
+		if interval.From.After(covered) {
+			uncovered = append(uncovered, TimeInterval{From: covered, To: minTime(interval.From, arrival_date)})
+		}
+		if interval.To.After(covered) {
+			covered = interval.To
+		}
+		if !covered.Before(arrival_date) {
+			return uncovered
+		}
+	}
+
+	if covered.Before(arrival_date) {
+		uncovered = append(uncovered, TimeInterval{From: covered, To: arrival_date})
+	}
+
+	return uncovered
+}
+
+func minTime(a time.Time, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+
+	return b
+}
+
+var ErrInvalidOperatingHours = errors.New("operating hours must be HH:MM")
+
+const clockLayout = "15:04"
+
+// ValidateOperatingHours проверяет часы работы региона: оба пустые (круглосуточно) или оба в формате HH:MM
+func ValidateOperatingHours(opens string, closes string) error {
+	if opens == "" && closes == "" {
+		return nil
+	}
+
+	if _, err := time.Parse(clockLayout, opens); err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidOperatingHours, opens)
+	}
+	if _, err := time.Parse(clockLayout, closes); err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidOperatingHours, closes)
+	}
+
+	return nil
+}
+
+// OperatingHours раскладывает ежедневные часы работы региона на отрезок [from, to).
+// Если closes не позже opens, окно переходит через полночь.
+func OperatingHours(from time.Time, to time.Time, opens string, closes string, loc *time.Location) []TimeInterval {
+	if opens == "" && closes == "" {
+		return []TimeInterval{{From: from, To: to}}
+	}
+
+	opensAt, err := time.Parse(clockLayout, opens)
+	if err != nil {
+		return []TimeInterval{}
+	}
+	closesAt, err := time.Parse(clockLayout, closes)
+	if err != nil {
+		return []TimeInterval{}
+	}
+
+	openOffset := time.Duration(opensAt.Hour())*time.Hour + time.Duration(opensAt.Minute())*time.Minute
+	closeOffset := time.Duration(closesAt.Hour())*time.Hour + time.Duration(closesAt.Minute())*time.Minute
+	if closeOffset <= openOffset {
+		closeOffset += 24 * time.Hour
+	}
+
+	local := from.In(loc)
+	// начинаем с предыдущего дня: его ночное окно может заходить в from
+	day := time.Date(local.Year(), local.Month(), local.Day()-1, 0, 0, 0, 0, loc)
+
+	intervals := []TimeInterval{}
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		window := TimeInterval{From: day.Add(openOffset), To: day.Add(closeOffset)}
+		if window.From.Before(from) {
+			window.From = from
+		}
+		if window.To.After(to) {
+			window.To = to
+		}
+		if window.From.Before(window.To) {
+			intervals = append(intervals, window)
+		}
+	}
+
+	return NormalizeIntervals(intervals)
+}
+
+// NormalizeIntervals сортирует отрезки и склеивает пересекающиеся и соседние
+func NormalizeIntervals(intervals []TimeInterval) []TimeInterval {
+	sorted := append([]TimeInterval{}, intervals...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].From.Before(sorted[j].From)
+	})
+
+	merged := []TimeInterval{}
+	for _, interval := range sorted {
+		if !interval.From.Before(interval.To) {
+			continue
+		}
+
+		last := len(merged) - 1
+		if last >= 0 && !interval.From.After(merged[last].To) {
+			if interval.To.After(merged[last].To) {
+				merged[last].To = interval.To
+			}
+			continue
+		}
+
+		merged = append(merged, interval)
+	}
+
+	return merged
+}
+
+// SubtractIntervals вырезает из отрезков a отрезки b
+func SubtractIntervals(a []TimeInterval, b []TimeInterval) []TimeInterval {
+	a, b = NormalizeIntervals(a), NormalizeIntervals(b)
+
+	result := []TimeInterval{}
+	for _, interval := range a {
+		from := interval.From
+		for _, cut := range b {
+			if !cut.To.After(from) {
+				continue
+			}
+			if !cut.From.Before(interval.To) {
+				break
+			}
+
+			if cut.From.After(from) {
+				result = append(result, TimeInterval{From: from, To: cut.From})
+			}
+			from = cut.To
+		}
+
+		if from.Before(interval.To) {
+			result = append(result, TimeInterval{From: from, To: interval.To})
+		}
+	}
+
+	return result
+}
+
+// IntersectIntervals пересекает два набора отрезков
+func IntersectIntervals(a []TimeInterval, b []TimeInterval) []TimeInterval {
+	a, b = NormalizeIntervals(a), NormalizeIntervals(b)
+
+	result := []TimeInterval{}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		from := a[i].From
+		if b[j].From.After(from) {
+			from = b[j].From
+		}
+		to := minTime(a[i].To, b[j].To)
+
+		if from.Before(to) {
+			result = append(result, TimeInterval{From: from, To: to})
+		}
+
+		if a[i].To.Before(b[j].To) {
+			i++
+		} else {
+			j++
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,248 @@
+package ds
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func span(from int, to int) TimeInterval {
+	return TimeInterval{From: at(from), To: at(to)}
+}
+
+func sameIntervals(a []TimeInterval, b []TimeInterval) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].From.Equal(b[i].From) || !a[i].To.Equal(b[i].To) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestValidateAllowedHours(t *testing.T) {
+	tests := []struct {
+		name      string
+		intervals []TimeInterval
+		wantErr   bool
+	}{
+		{name: "no intervals", intervals: nil},
+		{name: "inside the window", intervals: []TimeInterval{span(10, 11), span(12, 14)}},
+		{name: "the whole window", intervals: []TimeInterval{span(10, 14)}},
+		{name: "empty interval", intervals: []TimeInterval{span(11, 11)}, wantErr: true},
+		{name: "reversed interval", intervals: []TimeInterval{span(12, 11)}, wantErr: true},
+		{name: "starts before takeoff", intervals: []TimeInterval{span(9, 11)}, wantErr: true},
+		{name: "ends after arrival", intervals: []TimeInterval{span(13, 15)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAllowedHours(tt.intervals, at(10), at(14))
+			if tt.wantErr && !errors.Is(err, ErrInvalidAllowedHours) {
+				t.Fatalf("ValidateAllowedHours() error = %v, want %v", err, ErrInvalidAllowedHours)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("ValidateAllowedHours() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestUncoveredHours(t *testing.T) {
+	tests := []struct {
+		name      string
+		intervals []TimeInterval
+		want      []TimeInterval
+	}{
+		{name: "nothing allowed", intervals: nil, want: []TimeInterval{span(10, 14)}},
+		{name: "fully covered", intervals: []TimeInterval{span(10, 14)}, want: []TimeInterval{}},
+		{name: "unsorted with a gap", intervals: []TimeInterval{span(12, 14), span(10, 11)}, want: []TimeInterval{span(11, 12)}},
+		{name: "overlapping intervals", intervals: []TimeInterval{span(10, 12), span(11, 13)}, want: []TimeInterval{span(13, 14)}},
+		{name: "gap at takeoff", intervals: []TimeInterval{span(11, 14)}, want: []TimeInterval{span(10, 11)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := UncoveredHours(tt.intervals, at(10), at(14))
+			if !sameIntervals(got, tt.want) {
+				t.Fatalf("UncoveredHours() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateOperatingHours(t *testing.T) {
+	tests := []struct {
+		name    string
+		opens   string
+		closes  string
+		wantErr bool
+	}{
+		{name: "around the clock", opens: "", closes: ""},
+		{name: "day hours", opens: "08:00", closes: "20:00"},
+		{name: "overnight", opens: "22:00", closes: "06:00"},
+		{name: "only opens", opens: "08:00", closes: "", wantErr: true},
+		{name: "only closes", opens: "", closes: "20:00", wantErr: true},
+		{name: "bad hour", opens: "25:00", closes: "20:00", wantErr: true},
+		{name: "not a time", opens: "8am", closes: "20:00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateOperatingHours(tt.opens, tt.closes)
+			if tt.wantErr && !errors.Is(err, ErrInvalidOperatingHours) {
+				t.Fatalf("ValidateOperatingHours() error = %v, want %v", err, ErrInvalidOperatingHours)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("ValidateOperatingHours() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestOperatingHours(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	day := func(d int, hour int) time.Time {
+		return time.Date(2024, 1, d, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name   string
+		from   time.Time
+		to     time.Time
+		opens  string
+		closes string
+		loc    *time.Location
+		want   []TimeInterval
+	}{
+		{
+			name: "around the clock",
+			from: day(1, 0), to: day(3, 0),
+			loc:  time.UTC,
+			want: []TimeInterval{{From: day(1, 0), To: day(3, 0)}},
+		},
+		{
+			name: "day hours over two days",
+			from: day(1, 0), to: day(3, 0),
+			opens: "08:00", closes: "20:00",
+			loc:  time.UTC,
+			want: []TimeInterval{{From: day(1, 8), To: day(1, 20)}, {From: day(2, 8), To: day(2, 20)}},
+		},
+		{
+			name: "overnight window from the previous day reaches into the flight",
+			from: day(2, 2), to: day(2, 23),
+			opens: "22:00", closes: "06:00",
+			loc:  time.UTC,
+			want: []TimeInterval{{From: day(2, 2), To: day(2, 6)}, {From: day(2, 22), To: day(2, 23)}},
+		},
+		{
+			name: "hours are local to the region",
+			from: day(1, 0), to: day(2, 0),
+			opens: "08:00", closes: "20:00",
+			loc:  moscow,
+			want: []TimeInterval{{From: day(1, 5), To: day(1, 17)}},
+		},
+		{
+			name: "flight entirely while closed",
+			from: day(1, 21), to: day(1, 23),
+			opens: "08:00", closes: "20:00",
+			loc:  time.UTC,
+			want: []TimeInterval{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := OperatingHours(tt.from, tt.to, tt.opens, tt.closes, tt.loc)
+			if !sameIntervals(got, tt.want) {
+				t.Fatalf("OperatingHours() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeIntervals(t *testing.T) {
+	tests := []struct {
+		name      string
+		intervals []TimeInterval
+		want      []TimeInterval
+	}{
+		{name: "empty", intervals: nil, want: []TimeInterval{}},
+		{name: "sorts", intervals: []TimeInterval{span(12, 13), span(10, 11)}, want: []TimeInterval{span(10, 11), span(12, 13)}},
+		{name: "merges overlapping", intervals: []TimeInterval{span(10, 12), span(11, 13)}, want: []TimeInterval{span(10, 13)}},
+		{name: "merges adjacent", intervals: []TimeInterval{span(10, 11), span(11, 12)}, want: []TimeInterval{span(10, 12)}},
+		{name: "drops empty", intervals: []TimeInterval{span(10, 10), span(11, 12)}, want: []TimeInterval{span(11, 12)}},
+		{name: "swallows nested", intervals: []TimeInterval{span(10, 14), span(11, 12)}, want: []TimeInterval{span(10, 14)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NormalizeIntervals(tt.intervals)
+			if !sameIntervals(got, tt.want) {
+				t.Fatalf("NormalizeIntervals() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntersectIntervals(t *testing.T) {
+	tests := []struct {
+		name string
+		a    []TimeInterval
+		b    []TimeInterval
+		want []TimeInterval
+	}{
+		{name: "one side empty", a: []TimeInterval{span(10, 12)}, b: nil, want: []TimeInterval{}},
+		{name: "overlap", a: []TimeInterval{span(10, 12)}, b: []TimeInterval{span(11, 13)}, want: []TimeInterval{span(11, 12)}},
+		{name: "touching only", a: []TimeInterval{span(10, 11)}, b: []TimeInterval{span(11, 12)}, want: []TimeInterval{}},
+		{
+			name: "several pieces",
+			a:    []TimeInterval{span(8, 12), span(14, 18)},
+			b:    []TimeInterval{span(10, 15), span(17, 20)},
+			want: []TimeInterval{span(10, 12), span(14, 15), span(17, 18)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := IntersectIntervals(tt.a, tt.b)
+			if !sameIntervals(got, tt.want) {
+				t.Fatalf("IntersectIntervals() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubtractIntervals(t *testing.T) {
+	tests := []struct {
+		name string
+		a    []TimeInterval
+		b    []TimeInterval
+		want []TimeInterval
+	}{
+		{name: "nothing to cut", a: []TimeInterval{span(10, 12)}, b: nil, want: []TimeInterval{span(10, 12)}},
+		{name: "cut in the middle", a: []TimeInterval{span(10, 14)}, b: []TimeInterval{span(11, 12)}, want: []TimeInterval{span(10, 11), span(12, 14)}},
+		{name: "cut the start", a: []TimeInterval{span(10, 14)}, b: []TimeInterval{span(9, 11)}, want: []TimeInterval{span(11, 14)}},
+		{name: "cut the end", a: []TimeInterval{span(10, 14)}, b: []TimeInterval{span(13, 15)}, want: []TimeInterval{span(10, 13)}},
+		{name: "cut everything", a: []TimeInterval{span(10, 14)}, b: []TimeInterval{span(9, 15)}, want: []TimeInterval{}},
+		{
+			name: "one cut across two intervals",
+			a:    []TimeInterval{span(8, 10), span(12, 14)},
+			b:    []TimeInterval{span(9, 13)},
+			want: []TimeInterval{span(8, 9), span(13, 14)},
+		},
+		{name: "cut outside", a: []TimeInterval{span(10, 12)}, b: []TimeInterval{span(12, 13)}, want: []TimeInterval{span(10, 12)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SubtractIntervals(tt.a, tt.b)
+			if !sameIntervals(got, tt.want) {
+				t.Fatalf("SubtractIntervals() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package ds
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidLegs = errors.New("invalid flight legs")
+
+// ValidateLegs проверяет, что участки с заданным временем идут подряд без разрывов и перекрытий
+// и ровно покрывают окно полёта. Участки без времени допустимы, только если таких все.
+func ValidateLegs(legs []FlightToRegion, takeoff_date time.Time, arrival_date time.Time) error {
+	timed := 0
+	for _, leg := range legs {
+		if (leg.EntryDate == nil) != (leg.ExitDate == nil) {
+			return fmt.Errorf("%w: leg %d must have both entry and exit time", ErrInvalidLegs, leg.Sequence)
+		}
+		if leg.EntryDate != nil {
+			timed++
+		}
+	}
+
+	if timed == 0 {
+		return nil
+	}
+
+	if timed != len(legs) {
+		return fmt.Errorf("%w: either all legs or none must have entry and exit time", ErrInvalidLegs)
+	}
+
+	expected := takeoff_date
+	for _, leg := range legs {
+		if !leg.EntryDate.Equal(expected) {
+			return fmt.Errorf("%w: leg %d must start at %s", ErrInvalidLegs, leg.Sequence, expected.Format(time.RFC3339))
+		}
+		if !leg.ExitDate.After(*leg.EntryDate) {
+			return fmt.Errorf("%w: leg %d must end after it starts", ErrInvalidLegs, leg.Sequence)
+		}
+		expected = *leg.ExitDate
+	}
+
+	if !expected.Equal(arrival_date) {
+		return fmt.Errorf("%w: last leg must end at arrival %s", ErrInvalidLegs, arrival_date.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// ShiftLegs сдвигает время участков на delta, например при переносе полёта
+func ShiftLegs(legs []FlightToRegion, delta time.Duration) []FlightToRegion {
+	shifted := make([]FlightToRegion, 0, len(legs))
+	for _, leg := range legs {
+		if leg.EntryDate != nil {
+			entry := leg.EntryDate.Add(delta)
+			exit := leg.ExitDate.Add(delta)
+			leg.EntryDate = &entry
+			leg.ExitDate = &exit
+		}
+		shifted = append(shifted, leg)
+	}
+
+	return shifted
+}
+
+// LegWindow - когда участок занимает регион
+func LegWindow(leg FlightToRegion, takeoff_date time.Time, arrival_date time.Time) (time.Time, time.Time) {
+	if leg.EntryDate == nil || leg.ExitDate == nil {
+		return takeoff_date, arrival_date
+	}
+
+	return *leg.EntryDate, *leg.ExitDate
+}
@@ -0,0 +1,93 @@
+package ds
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func at(hour int) time.Time {
+	return time.Date(2024, 1, 1, hour, 0, 0, 0, time.UTC)
+}
+
+func timedLeg(sequence int, from int, to int) FlightToRegion {
+	entry, exit := at(from), at(to)
+	return FlightToRegion{Sequence: sequence, RegionRefer: sequence, EntryDate: &entry, ExitDate: &exit}
+}
+
+func TestValidateLegs(t *testing.T) {
+	halfTimed := FlightToRegion{Sequence: 2, RegionRefer: 2, EntryDate: timePtr(at(11))}
+
+	tests := []struct {
+		name    string
+		legs    []FlightToRegion
+		wantErr bool
+	}{
+		{name: "no legs", legs: nil},
+		{name: "untimed legs", legs: []FlightToRegion{{Sequence: 1}, {Sequence: 2}}},
+		{name: "legs cover the window", legs: []FlightToRegion{timedLeg(1, 10, 11), timedLeg(2, 11, 12)}},
+		{name: "single leg covers the window", legs: []FlightToRegion{timedLeg(1, 10, 12)}},
+		{name: "timed and untimed mixed", legs: []FlightToRegion{timedLeg(1, 10, 12), {Sequence: 2}}, wantErr: true},
+		{name: "entry without exit", legs: []FlightToRegion{timedLeg(1, 10, 11), halfTimed}, wantErr: true},
+		{name: "gap between legs", legs: []FlightToRegion{timedLeg(1, 10, 11), {Sequence: 2, EntryDate: timePtr(at(11).Add(time.Minute)), ExitDate: timePtr(at(12))}}, wantErr: true},
+		{name: "overlapping legs", legs: []FlightToRegion{timedLeg(1, 10, 12), timedLeg(2, 11, 12)}, wantErr: true},
+		{name: "starts after takeoff", legs: []FlightToRegion{timedLeg(1, 11, 12)}, wantErr: true},
+		{name: "ends before arrival", legs: []FlightToRegion{timedLeg(1, 10, 11)}, wantErr: true},
+		{name: "zero length leg", legs: []FlightToRegion{timedLeg(1, 10, 10), timedLeg(2, 10, 12)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLegs(tt.legs, at(10), at(12))
+			if tt.wantErr && !errors.Is(err, ErrInvalidLegs) {
+				t.Fatalf("ValidateLegs() error = %v, want %v", err, ErrInvalidLegs)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("ValidateLegs() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestShiftLegs(t *testing.T) {
+	legs := []FlightToRegion{timedLeg(1, 10, 11), timedLeg(2, 11, 12)}
+
+	shifted := ShiftLegs(legs, 2*time.Hour)
+
+	if err := ValidateLegs(shifted, at(12), at(14)); err != nil {
+		t.Fatalf("shifted legs don't cover the shifted window: %v", err)
+	}
+	if !legs[0].EntryDate.Equal(at(10)) {
+		t.Fatalf("ShiftLegs changed the original legs: entry = %v", legs[0].EntryDate)
+	}
+
+	untimed := ShiftLegs([]FlightToRegion{{Sequence: 1}}, time.Hour)
+	if untimed[0].EntryDate != nil || untimed[0].ExitDate != nil {
+		t.Fatalf("ShiftLegs gave time to an untimed leg: %+v", untimed[0])
+	}
+}
+
+func TestLegWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		leg      FlightToRegion
+		wantFrom time.Time
+		wantTo   time.Time
+	}{
+		{name: "timed leg", leg: timedLeg(1, 11, 12), wantFrom: at(11), wantTo: at(12)},
+		{name: "untimed leg takes the whole flight", leg: FlightToRegion{Sequence: 1}, wantFrom: at(10), wantTo: at(13)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := LegWindow(tt.leg, at(10), at(13))
+			if !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) {
+				t.Fatalf("LegWindow() = %v - %v, want %v - %v", from, to, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
+
+func timePtr(value time.Time) *time.Time {
+	return &value
+}
@@ -0,0 +1,19 @@
+package ds
+
+import "errors"
+
+// ErrOutboxLeaseLost - аренда сообщения истекла и его забрал другой диспетчер
+var ErrOutboxLeaseLost = errors.New("outbox message lease was taken over by another dispatcher")
+
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxDead    = "dead" // попытки кончились, нужен человек
+)
+
+// AllowedHoursTopic - заявка подтверждена пользователем, сервису расчёта нужно выставить разрешённые часы
+const AllowedHoursTopic = "allowed_hours"
+
+type AllowedHoursPayload struct {
+	PK string `json:"pk"`
+}
@@ -1,31 +1,145 @@
 package ds
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type BookRequestBody struct {
 	TakeoffDate string
 	ArrivalDate string
 	Regions     []string
 	Status      string
+	Legs        []FlightLegRequest // упорядоченные участки маршрута; если заданы, Regions не используется
+	RRule       string             // правило повторения по RFC 5545, например "FREQ=WEEKLY;BYDAY=TU,TH;COUNT=8"
+	ExDates     []string           // даты в RFC3339, в которые полёт по правилу не нужен
+	Route       *RouteRequest      // маршрут; если задан, регионы и участки определяются по нему, а Regions и Legs не используются
+	MinAltitude int                // планируемый диапазон высот, м над землёй
+	MaxAltitude int
+}
+
+// RouteRequest - маршрут полёта: GeoJSON LineString в Path или список точек в Waypoints
+type RouteRequest struct {
+	Path          json.RawMessage `swaggertype:"object"` // {"type": "LineString", "coordinates": [[долгота, широта], ...]}
+	Waypoints     [][2]float64    // [долгота, широта], если Path не задан
+	CorridorWidth float64         // полная ширина коридора в метрах, 0 - только сама линия
 }
 
 type EditFlightRequestBody struct {
 	FlightID    int       `json:"flightID"`
 	TakeoffDate time.Time `json:"takeoffDate"`
 	ArrivalDate time.Time `json:"arrivalDate"`
+	MinAltitude int       `json:"minAltitude"` // диапазон высот меняется целиком, 0..0 - оставить прежний
+	MaxAltitude int       `json:"maxAltitude"`
+}
+
+type CloneFlightRequestBody struct {
+	TakeoffDate string // новое время взлёта в RFC3339, длительность берётся из исходной заявки
+	Merge       bool   // если у пользователя уже есть черновик - дописать регионы в него
+}
+
+// ResubmitFlightRequestBody - правки к отклонённой заявке. Пустые поля берутся из неё самой.
+type ResubmitFlightRequestBody struct {
+	TakeoffDate string // RFC3339; если не указано ArrivalDate, длительность полёта сохраняется
+	ArrivalDate string
+	Regions     []string
+	Legs        []FlightLegRequest
+}
+
+type FlightLegRequest struct {
+	Region    string
+	EntryDate string // RFC3339, вместе с ExitDate можно не указывать
+	ExitDate  string
 }
 
 type SetFlightRegionsRequestBody struct {
 	FlightID int
 	Regions  []string
+	Legs     []FlightLegRequest
 }
 
 type ChangeFlightStatusRequestBody struct {
 	ID     int
 	Status string
+	Reason string
+}
+
+type CancelFlightRequestBody struct {
+	Reason string
+}
+
+type FlightReportRequestBody struct {
+	Date string // фактическое время в RFC3339, по умолчанию - время запроса
+}
+
+type SetAllowedHoursRequestBody struct {
+	FlightID     int            `json:"id"`
+	AllowedHours []TimeInterval `json:"allowed_hours"`
 }
 
 type DeleteFlightToRegionRequestBody struct {
 	FlightID int
 	RegionID int
 }
+
+// ModerationDecision - решение модератора по заявке. При отклонении обязательны код причины и пояснение,
+// комментарий можно оставить и при одобрении (например, условия полёта).
+type ModerationDecision struct {
+	Confirm bool
+	Code    string
+	Reason  string
+	Comment string
+	Version int // версия заявки из If-Match, 0 - не проверять
+}
+
+type ModConfirmFlightRequestBody struct {
+	Code    string
+	Reason  string
+	Comment string
+}
+
+type BatchDecision struct {
+	FlightID int
+	Confirm  bool
+	Code     string
+	Reason   string
+	Comment  string
+	Version  int // версия заявки, как в If-Match; без неё заявка не решается
+}
+
+type BatchModerationRequestBody struct {
+	Decisions []BatchDecision
+}
+
+type SeriesDecisionRequestBody struct {
+	Confirm   bool
+	Code      string
+	Reason    string
+	Comment   string
+	Overrides map[uint]bool // решения по отдельным полётам серии, отличные от общего
+	Versions  map[uint]int  // версии полётов из ETag; полёт без версии не решается
+}
+
+type SeriesCancelRequestBody struct {
+	Reason string
+	Keep   []uint // полёты серии, которые отменять не нужно
+}
+
+// CreateRestrictionRequestBody - область задаётся полигоном Boundary, списком регионов или и тем и другим
+type CreateRestrictionRequestBody struct {
+	Reason      string
+	Boundary    json.RawMessage `swaggertype:"object"`
+	Regions     []string
+	StartsAt    string // RFC3339
+	EndsAt      string
+	MinAltitude int
+	MaxAltitude int
+	Blocking    *bool // по умолчанию true
+}
+
+// DistrictRequestBody - при редактировании пустые поля не меняются, Moderators = nil оставляет модераторов как есть
+type DistrictRequestBody struct {
+	Name       string
+	Boundary   json.RawMessage `swaggertype:"object"`
+	Moderators []string        // логины модераторов, отвечающих за округ
+}
@@ -0,0 +1,92 @@
+package ds
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+var ErrInvalidRestriction = errors.New("invalid restriction")
+
+// Restriction - временное ограничение полётов (как NOTAM): над областью и/или регионами, в окне времени и диапазоне высот.
+// Области сопоставляются регионы, которые она задевает; заявки проверяются по этим регионам,
+// а заявки с маршрутом - ещё и по самой области.
+type Restriction struct {
+	ID           uint           `gorm:"primaryKey;AUTO_INCREMENT"`
+	Reason       string         `gorm:"type:text;not null"`
+	Boundary     datatypes.JSON `swaggertype:"object"` // GeoJSON Polygon или MultiPolygon, можно не указывать, если заданы регионы
+	StartsAt     time.Time      `gorm:"not null;index" swaggertype:"primitive,string"`
+	EndsAt       time.Time      `gorm:"not null;index" swaggertype:"primitive,string"`
+	MinAltitude  int            `gorm:"not null;default:0"` // м над землёй
+	MaxAltitude  int            `gorm:"not null"`
+	Blocking     bool           `gorm:"not null;default:true"` // false - заявки не отклоняются, а только помечаются
+	CreatorRefer *uuid.UUID     `gorm:"type:uuid;not null"`
+	DateCreated  time.Time      `gorm:"not null" swaggertype:"primitive,string"`
+	DateLifted   *time.Time     `swaggertype:"primitive,string"` // ограничение снято досрочно
+	Creator      User           `gorm:"foreignKey:CreatorRefer;references:UUID" json:"-"`
+	Regions      []string       `gorm:"-"`
+}
+
+type RestrictionToRegion struct {
+	ID               uint        `gorm:"primaryKey;AUTO_INCREMENT"`
+	RestrictionRefer int         `gorm:"not null;index"`
+	RegionRefer      int         `gorm:"not null;index"`
+	Restriction      Restriction `gorm:"foreignKey:RestrictionRefer"`
+	Region           Region      `gorm:"foreignKey:RegionRefer"`
+}
+
+// RestrictionHit - ограничение, которое задевает участок заявки
+type RestrictionHit struct {
+	RestrictionID uint
+	Reason        string
+	StartsAt      time.Time `swaggertype:"primitive,string"`
+	EndsAt        time.Time `swaggertype:"primitive,string"`
+	MinAltitude   int
+	MaxAltitude   int
+	RegionID      uint
+	RegionName    string
+	Blocking      bool
+}
+
+type RestrictionError struct {
+	Hits []RestrictionHit
+}
+
+func (e *RestrictionError) Error() string {
+	return fmt.Sprintf("flight crosses %d active airspace restriction(s)", len(e.Hits))
+}
+
+func BlockingRestrictions(hits []RestrictionHit) []RestrictionHit {
+	blocking := []RestrictionHit{}
+	for _, hit := range hits {
+		if hit.Blocking {
+			blocking = append(blocking, hit)
+		}
+	}
+
+	return blocking
+}
+
+func ValidateRestriction(restriction Restriction, hasArea bool) error {
+	if strings.TrimSpace(restriction.Reason) == "" {
+		return fmt.Errorf("%w: reason is required", ErrInvalidRestriction)
+	}
+
+	if !restriction.StartsAt.Before(restriction.EndsAt) {
+		return fmt.Errorf("%w: restriction must end after it starts", ErrInvalidRestriction)
+	}
+
+	if restriction.MinAltitude < 0 || restriction.MinAltitude >= restriction.MaxAltitude {
+		return fmt.Errorf("%w: altitude band must satisfy 0 <= min < max", ErrInvalidRestriction)
+	}
+
+	if !hasArea {
+		return fmt.Errorf("%w: boundary or regions are required", ErrInvalidRestriction)
+	}
+
+	return nil
+}
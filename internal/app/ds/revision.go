@@ -0,0 +1,81 @@
+package ds
+
+import "time"
+
+type TimeChange struct {
+	Old time.Time `swaggertype:"primitive,string"`
+	New time.Time `swaggertype:"primitive,string"`
+}
+
+// FlightRevisionDiff - что пользователь поменял в заявке после того, как её отклонили.
+// Незаполненные поля не менялись.
+type FlightRevisionDiff struct {
+	ParentID        uint
+	RejectionCode   string
+	RejectionReason string
+	TakeoffDate     *TimeChange `json:",omitempty"`
+	ArrivalDate     *TimeChange `json:",omitempty"`
+	AddedRegions    []string
+	RemovedRegions  []string
+	LegsChanged     bool // изменился порядок участков или время над регионами относительно взлёта
+}
+
+func DiffRevision(parent Flight, parentLegs []FlightLeg, revision Flight, revisionLegs []FlightLeg) FlightRevisionDiff {
+	diff := FlightRevisionDiff{
+		ParentID:        parent.ID,
+		RejectionCode:   parent.RejectionCode,
+		RejectionReason: parent.RejectionReason,
+		AddedRegions:    []string{},
+		RemovedRegions:  []string{},
+	}
+
+	if !parent.TakeoffDate.Equal(revision.TakeoffDate) {
+		diff.TakeoffDate = &TimeChange{Old: parent.TakeoffDate, New: revision.TakeoffDate}
+	}
+	if !parent.ArrivalDate.Equal(revision.ArrivalDate) {
+		diff.ArrivalDate = &TimeChange{Old: parent.ArrivalDate, New: revision.ArrivalDate}
+	}
+
+	parentRegions := map[string]bool{}
+	for _, leg := range parentLegs {
+		parentRegions[leg.Region] = true
+	}
+	revisionRegions := map[string]bool{}
+	for _, leg := range revisionLegs {
+		revisionRegions[leg.Region] = true
+		if !parentRegions[leg.Region] {
+			diff.AddedRegions = append(diff.AddedRegions, leg.Region)
+		}
+	}
+	for _, leg := range parentLegs {
+		if !revisionRegions[leg.Region] {
+			diff.RemovedRegions = append(diff.RemovedRegions, leg.Region)
+		}
+	}
+
+	if len(parentLegs) != len(revisionLegs) {
+		diff.LegsChanged = true
+		return diff
+	}
+
+	for i := range parentLegs {
+		before, after := parentLegs[i], revisionLegs[i]
+		if before.Region != after.Region ||
+			!sameOffset(before.EntryDate, parent.TakeoffDate, after.EntryDate, revision.TakeoffDate) ||
+			!sameOffset(before.ExitDate, parent.TakeoffDate, after.ExitDate, revision.TakeoffDate) {
+			diff.LegsChanged = true
+			break
+		}
+	}
+
+	return diff
+}
+
+// sameOffset сравнивает моменты относительно взлёта, чтобы простой перенос полёта не считался правкой маршрута
+func sameOffset(a *time.Time, aTakeoff time.Time, b *time.Time, bTakeoff time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return a.Sub(aTakeoff) == b.Sub(bTakeoff)
+}
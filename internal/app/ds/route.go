@@ -0,0 +1,20 @@
+package ds
+
+import "gorm.io/datatypes"
+
+// FlightRoute - проверенный маршрут и регионы, которые задевает его коридор, в порядке пролёта
+type FlightRoute struct {
+	Path          datatypes.JSON
+	CorridorWidth float64
+	RegionIDs     []int
+}
+
+// RouteLegs - участки по регионам маршрута. Время над регионами не задаётся: по маршруту его не узнать.
+func RouteLegs(route FlightRoute) []FlightToRegion {
+	legs := []FlightToRegion{}
+	for _, region_id := range route.RegionIDs {
+		legs = append(legs, FlightToRegion{RegionRefer: region_id, Sequence: len(legs) + 1})
+	}
+
+	return legs
+}
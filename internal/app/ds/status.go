@@ -1,5 +1,14 @@
 package ds
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"drones/internal/app/role"
+)
+
 type RegionStatus int
 type FlightStatus int
 
@@ -9,9 +18,145 @@ const (
 	Completed
 	Rejected
 	Deleted
+	Finished
+	Cancelled
+	InFlight
+	Landed
 )
 
 const (
 	Active RegionStatus = iota
 	Inactive
 )
+
+var ErrUnknownFlightStatus = errors.New("unknown flight status")
+var ErrFlightNotOwned = errors.New("flight belongs to another user")
+var ErrDraftSeries = errors.New("recurring flights can't be booked as drafts")
+var ErrInvalidRecurrence = errors.New("invalid recurrence rule")
+var ErrDraftExists = errors.New("user already has a draft flight")
+var ErrAlreadyResubmitted = errors.New("rejected flight already has a new revision")
+var ErrCancelReason = errors.New("cancellation requires a reason")
+var ErrFlightDeparted = errors.New("flight has already taken off")
+var ErrOutsideFlightWindow = errors.New("reported time is outside the approved flight window")
+var ErrVersionMismatch = errors.New("row was modified by someone else")
+var ErrVersionRequired = errors.New("version of the row is required")
+var ErrFlightNotFormed = errors.New("flight is not awaiting moderation")
+var ErrFlightNotDraft = errors.New("only draft flights can be edited")
+var ErrRejectionReason = errors.New("rejection requires a known reason code and an explanation")
+var ErrRouteOutsideRegions = errors.New("route doesn't cross any region")
+var ErrDedicatedTransition = errors.New("flight status transition must go through its own endpoint")
+
+// статус удалённого региона, над ним не летают
+const UnavailableRegionStatus = "Недоступен"
+
+// код причины, с которым заявки отклоняет сам сервис
+const ExpiredRejectionCode = "expired"
+
+// названия статусов в том виде, в котором они хранятся в БД и уходят на фронт
+var flightStatusNames = map[FlightStatus]string{
+	Draft:     "Черновик",
+	Formed:    "Сформирован",
+	Completed: "Завершён",
+	Rejected:  "Отклонён",
+	Deleted:   "Удалён",
+	Finished:  "Выполнен",
+	Cancelled: "Отменён",
+	InFlight:  "В полёте",
+	Landed:    "Приземлился",
+}
+
+// flightTransitions - таблица допустимых переходов: из какого статуса, в какой и какими ролями
+var flightTransitions = map[FlightStatus]map[FlightStatus][]role.Role{
+	Draft: {
+		Formed:  {role.User, role.Moderator, role.Admin},
+		Deleted: {role.User, role.Moderator, role.Admin, role.System},
+	},
+	Formed: {
+		Completed: {role.Moderator, role.Admin},
+		Rejected:  {role.Moderator, role.Admin, role.System},
+		Deleted:   {role.User, role.Moderator, role.Admin},
+	},
+	Completed: {
+		Finished:  {role.System},
+		Cancelled: {role.User, role.Admin},
+		InFlight:  {role.User, role.Admin},
+	},
+	InFlight: {
+		Landed: {role.User, role.Admin},
+	},
+	Rejected: {
+		Deleted: {role.Moderator, role.Admin},
+	},
+}
+
+// genericFlightStatuses - статусы, в которые заявку можно перевести общим эндпоинтом смены статуса.
+// Подача, модерация, взлёт и посадка идут только через свои методы, которые проверяют конфликты, вместимость, окна и версию.
+var genericFlightStatuses = map[FlightStatus]bool{
+	Deleted:   true,
+	Cancelled: true,
+}
+
+type TransitionError struct {
+	From FlightStatus
+	To   FlightStatus
+	Role role.Role
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("flight status transition %q -> %q is not allowed for role %d", e.From, e.To, e.Role)
+}
+
+func (s FlightStatus) String() string {
+	name, ok := flightStatusNames[s]
+	if !ok {
+		return fmt.Sprintf("FlightStatus(%d)", int(s))
+	}
+
+	return name
+}
+
+func ParseFlightStatus(name string) (FlightStatus, error) {
+	for status, statusName := range flightStatusNames {
+		if statusName == name {
+			return status, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %q", ErrUnknownFlightStatus, name)
+}
+
+func CheckFlightTransition(from FlightStatus, to FlightStatus, actorRole role.Role) error {
+	for _, allowedRole := range flightTransitions[from][to] {
+		if allowedRole == actorRole {
+			return nil
+		}
+	}
+
+	return &TransitionError{
+		From: from,
+		To:   to,
+		Role: actorRole,
+	}
+}
+
+// CheckGenericFlightTransition отсекает переходы, у которых есть отдельный метод
+func CheckGenericFlightTransition(to FlightStatus) error {
+	if !genericFlightStatuses[to] {
+		return fmt.Errorf("%w: %q", ErrDedicatedTransition, to)
+	}
+
+	return nil
+}
+
+// CheckCancellation - одобренную заявку можно отменить только до взлёта и с объяснением причины
+func CheckCancellation(flight Flight, reason string, now time.Time) error {
+	if strings.TrimSpace(reason) == "" {
+		return ErrCancelReason
+	}
+
+	if !now.Before(flight.TakeoffDate) {
+		return ErrFlightDeparted
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Геометрия хранится в GeoJSON (RFC 7946): координаты в градусах WGS84, порядок [долгота, широта].
+
+var ErrInvalidGeometry = errors.New("invalid geometry")
+
+// сколько точек допускается в одном контуре, проверка самопересечений квадратичная
+const maxRingPoints = 5000
+
+type Point struct {
+	Lon float64
+	Lat float64
+}
+
+// Polygon - внешний контур и дырки. Контуры замкнуты: первая точка совпадает с последней.
+type Polygon [][]Point
+
+type MultiPolygon []Polygon
+
+type BBox struct {
+	MinLon float64
+	MinLat float64
+	MaxLon float64
+	MaxLat float64
+}
+
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// ParseBoundary разбирает и проверяет Polygon или MultiPolygon
+func ParseBoundary(data []byte) (MultiPolygon, error) {
+	var raw geoJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGeometry, err)
+	}
+
+	var polygons MultiPolygon
+	switch raw.Type {
+	case "Polygon":
+		var coordinates [][][]float64
+		if err := json.Unmarshal(raw.Coordinates, &coordinates); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidGeometry, err)
+		}
+		polygons = MultiPolygon{toPolygon(coordinates)}
+	case "MultiPolygon":
+		var coordinates [][][][]float64
+		if err := json.Unmarshal(raw.Coordinates, &coordinates); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidGeometry, err)
+		}
+		for _, polygon := range coordinates {
+			polygons = append(polygons, toPolygon(polygon))
+		}
+	default:
+		return nil, fmt.Errorf("%w: expected Polygon or MultiPolygon, got %q", ErrInvalidGeometry, raw.Type)
+	}
+
+	if len(polygons) == 0 {
+		return nil, fmt.Errorf("%w: no polygons", ErrInvalidGeometry)
+	}
+
+	for i, polygon := range polygons {
+		if err := validatePolygon(polygon); err != nil {
+			return nil, fmt.Errorf("polygon %d: %w", i+1, err)
+		}
+	}
+
+	return polygons, nil
+}
+
+func toPolygon(coordinates [][][]float64) Polygon {
+	polygon := Polygon{}
+	for _, ring := range coordinates {
+		points := []Point{}
+		for _, position := range ring {
+			point := Point{Lon: math.NaN(), Lat: math.NaN()}
+			if len(position) >= 2 {
+				point = Point{Lon: position[0], Lat: position[1]}
+			}
+			points = append(points, point)
+		}
+		polygon = append(polygon, points)
+	}
+
+	return polygon
+}
+
+func validatePolygon(polygon Polygon) error {
+	if len(polygon) == 0 {
+		return fmt.Errorf("%w: polygon has no rings", ErrInvalidGeometry)
+	}
+
+	for i, ring := range polygon {
+		if len(ring) < 4 {
+			return fmt.Errorf("%w: ring %d must have at least 4 positions", ErrInvalidGeometry, i+1)
+		}
+		if len(ring) > maxRingPoints {
+			return fmt.Errorf("%w: ring %d has more than %d positions", ErrInvalidGeometry, i+1, maxRingPoints)
+		}
+
+		for _, point := range ring {
+			if math.IsNaN(point.Lon) || math.IsNaN(point.Lat) || point.Lon < -180 || point.Lon > 180 || point.Lat < -90 || point.Lat > 90 {
+				return fmt.Errorf("%w: ring %d has a position outside of [-180, 180] x [-90, 90]", ErrInvalidGeometry, i+1)
+			}
+		}
+
+		if ring[0] != ring[len(ring)-1] {
+			return fmt.Errorf("%w: ring %d is not closed", ErrInvalidGeometry, i+1)
+		}
+
+		if ringArea(ring) == 0 {
+			return fmt.Errorf("%w: ring %d has zero area", ErrInvalidGeometry, i+1)
+		}
+
+		if selfIntersects(ring) {
+			return fmt.Errorf("%w: ring %d intersects itself", ErrInvalidGeometry, i+1)
+		}
+	}
+
+	for i, hole := range polygon[1:] {
+		if !ringContains(polygon[0], hole[0]) {
+			return fmt.Errorf("%w: hole %d is outside of the outer ring", ErrInvalidGeometry, i+1)
+		}
+	}
+
+	return nil
+}
+
+// selfIntersects проверяет, пересекаются ли несоседние рёбра контура
+func selfIntersects(ring []Point) bool {
+	edges := len(ring) - 1
+	for i := 0; i < edges; i++ {
+		for j := i + 1; j < edges; j++ {
+			// соседние рёбра делят вершину, в том числе первое и последнее
+			if j == i+1 || (i == 0 && j == edges-1) {
+				continue
+			}
+			if segmentsIntersect(ring[i], ring[i+1], ring[j], ring[j+1]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func segmentsIntersect(a Point, b Point, c Point, d Point) bool {
+	d1 := orientation(c, d, a)
+	d2 := orientation(c, d, b)
+	d3 := orientation(a, b, c)
+	d4 := orientation(a, b, d)
+
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+
+	return (d1 == 0 && onSegment(c, d, a)) || (d2 == 0 && onSegment(c, d, b)) ||
+		(d3 == 0 && onSegment(a, b, c)) || (d4 == 0 && onSegment(a, b, d))
+}
+
+func orientation(a Point, b Point, c Point) float64 {
+	return (b.Lon-a.Lon)*(c.Lat-a.Lat) - (b.Lat-a.Lat)*(c.Lon-a.Lon)
+}
+
+func onSegment(a Point, b Point, p Point) bool {
+	return math.Min(a.Lon, b.Lon) <= p.Lon && p.Lon <= math.Max(a.Lon, b.Lon) &&
+		math.Min(a.Lat, b.Lat) <= p.Lat && p.Lat <= math.Max(a.Lat, b.Lat)
+}
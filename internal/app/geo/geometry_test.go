@@ -0,0 +1,200 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// square - квадрат со стороной size с юго-западным углом в (lon, lat), обход против часовой стрелки
+func square(lon float64, lat float64, size float64) Polygon {
+	return Polygon{{
+		{Lon: lon, Lat: lat},
+		{Lon: lon + size, Lat: lat},
+		{Lon: lon + size, Lat: lat + size},
+		{Lon: lon, Lat: lat + size},
+		{Lon: lon, Lat: lat},
+	}}
+}
+
+func TestParseBoundary(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         string
+		wantPolygons int
+		wantErr      bool
+	}{
+		{
+			name:         "polygon",
+			data:         `{"type":"Polygon","coordinates":[[[37,55],[38,55],[38,56],[37,56],[37,55]]]}`,
+			wantPolygons: 1,
+		},
+		{
+			name:         "polygon with a hole",
+			data:         `{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,10],[0,0]],[[2,2],[4,2],[4,4],[2,4],[2,2]]]}`,
+			wantPolygons: 1,
+		},
+		{
+			name:         "multipolygon split at the antimeridian",
+			data:         `{"type":"MultiPolygon","coordinates":[[[[170,60],[180,60],[180,70],[170,70],[170,60]]],[[[-180,60],[-170,60],[-170,70],[-180,70],[-180,60]]]]}`,
+			wantPolygons: 2,
+		},
+		{name: "not json", data: `{`, wantErr: true},
+		{name: "point", data: `{"type":"Point","coordinates":[37,55]}`, wantErr: true},
+		{name: "empty multipolygon", data: `{"type":"MultiPolygon","coordinates":[]}`, wantErr: true},
+		{name: "too few positions", data: `{"type":"Polygon","coordinates":[[[0,0],[1,0],[0,0]]]}`, wantErr: true},
+		{name: "not closed", data: `{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1]]]}`, wantErr: true},
+		{name: "longitude past the antimeridian", data: `{"type":"Polygon","coordinates":[[[170,60],[190,60],[190,70],[170,70],[170,60]]]}`, wantErr: true},
+		{name: "latitude out of range", data: `{"type":"Polygon","coordinates":[[[0,80],[1,80],[1,91],[0,91],[0,80]]]}`, wantErr: true},
+		{name: "position without latitude", data: `{"type":"Polygon","coordinates":[[[0,0],[1],[1,1],[0,1],[0,0]]]}`, wantErr: true},
+		{name: "zero area", data: `{"type":"Polygon","coordinates":[[[0,0],[1,0],[2,0],[1,0],[0,0]]]}`, wantErr: true},
+		{name: "bow tie", data: `{"type":"Polygon","coordinates":[[[0,0],[1,1],[1,0],[0,1],[0,0]]]}`, wantErr: true},
+		{name: "hole outside", data: `{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,10],[0,0]],[[20,20],[21,20],[21,21],[20,21],[20,20]]]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			polygons, err := ParseBoundary([]byte(tt.data))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidGeometry) {
+					t.Fatalf("ParseBoundary() error = %v, want %v", err, ErrInvalidGeometry)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseBoundary() error = %v", err)
+			}
+			if len(polygons) != tt.wantPolygons {
+				t.Fatalf("ParseBoundary() gave %d polygons, want %d", len(polygons), tt.wantPolygons)
+			}
+		})
+	}
+}
+
+func TestContains(t *testing.T) {
+	withHole := MultiPolygon{{square(0, 0, 10)[0], square(2, 2, 2)[0]}}
+	antimeridian := MultiPolygon{square(170, 60, 10), square(-180, 60, 10)}
+
+	tests := []struct {
+		name  string
+		shape MultiPolygon
+		point Point
+		want  bool
+	}{
+		{name: "inside", shape: MultiPolygon{square(0, 0, 10)}, point: Point{Lon: 5, Lat: 5}, want: true},
+		{name: "outside", shape: MultiPolygon{square(0, 0, 10)}, point: Point{Lon: 15, Lat: 5}},
+		{name: "in the hole", shape: withHole, point: Point{Lon: 3, Lat: 3}},
+		{name: "around the hole", shape: withHole, point: Point{Lon: 5, Lat: 5}, want: true},
+		{name: "east of the antimeridian", shape: antimeridian, point: Point{Lon: 179.5, Lat: 65}, want: true},
+		{name: "west of the antimeridian", shape: antimeridian, point: Point{Lon: -179.5, Lat: 65}, want: true},
+		{name: "between the antimeridian parts", shape: antimeridian, point: Point{Lon: 0, Lat: 65}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.shape.Contains(tt.point); got != tt.want {
+				t.Fatalf("Contains(%v) = %v, want %v", tt.point, got, tt.want)
+			}
+		})
+	}
+}
+
+// Точка на общей границе соседних регионов должна попадать ровно в один из них,
+// иначе участок маршрута по границе окажется либо ничьим, либо в двух регионах сразу.
+func TestContainsPointOnEdge(t *testing.T) {
+	west := MultiPolygon{square(0, 0, 10)}
+	east := MultiPolygon{square(10, 0, 10)}
+	north := MultiPolygon{square(0, 10, 10)}
+
+	tests := []struct {
+		name  string
+		a     MultiPolygon
+		b     MultiPolygon
+		point Point
+	}{
+		{name: "vertical shared edge", a: west, b: east, point: Point{Lon: 10, Lat: 5}},
+		{name: "horizontal shared edge", a: west, b: north, point: Point{Lon: 5, Lat: 10}},
+		{name: "shared vertex", a: west, b: east, point: Point{Lon: 10, Lat: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.a.Contains(tt.point) == tt.b.Contains(tt.point) {
+				t.Fatalf("point %v belongs to both or neither: %v, %v", tt.point, tt.a.Contains(tt.point), tt.b.Contains(tt.point))
+			}
+		})
+	}
+}
+
+func TestIntersectsBox(t *testing.T) {
+	shape := MultiPolygon{{square(0, 0, 10)[0], square(2, 2, 6)[0]}}
+
+	tests := []struct {
+		name string
+		box  BBox
+		want bool
+	}{
+		{name: "box inside the polygon", box: BBox{MinLon: 0.5, MinLat: 0.5, MaxLon: 1, MaxLat: 1}, want: true},
+		{name: "polygon inside the box", box: BBox{MinLon: -1, MinLat: -1, MaxLon: 11, MaxLat: 11}, want: true},
+		{name: "box crosses an edge", box: BBox{MinLon: 9, MinLat: 4, MaxLon: 12, MaxLat: 5}, want: true},
+		{name: "box outside", box: BBox{MinLon: 11, MinLat: 11, MaxLon: 12, MaxLat: 12}},
+		{name: "box inside the hole", box: BBox{MinLon: 4, MinLat: 4, MaxLon: 5, MaxLat: 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shape.IntersectsBox(tt.box); got != tt.want {
+				t.Fatalf("IntersectsBox(%+v) = %v, want %v", tt.box, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntersects(t *testing.T) {
+	shape := MultiPolygon{square(0, 0, 10)}
+
+	tests := []struct {
+		name  string
+		other MultiPolygon
+		want  bool
+	}{
+		{name: "overlapping", other: MultiPolygon{square(5, 5, 10)}, want: true},
+		{name: "inside", other: MultiPolygon{square(2, 2, 2)}, want: true},
+		{name: "containing", other: MultiPolygon{square(-5, -5, 20)}, want: true},
+		{name: "crossing without vertices inside", other: MultiPolygon{{{{-1, 4}, {11, 4}, {11, 6}, {-1, 6}, {-1, 4}}}}, want: true},
+		{name: "apart", other: MultiPolygon{square(20, 20, 5)}},
+		{name: "bounding boxes overlap only", other: MultiPolygon{{{{9, 12}, {12, 9}, {12, 12}, {9, 12}}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shape.Intersects(tt.other); got != tt.want {
+				t.Fatalf("Intersects() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAreaKm2(t *testing.T) {
+	tests := []struct {
+		name  string
+		shape MultiPolygon
+		want  float64
+	}{
+		// градус на градус у экватора - около 111.2 x 111.2 км
+		{name: "degree at the equator", shape: MultiPolygon{square(0, 0, 1)}, want: 12364},
+		// на 60° параллели градус долготы вдвое короче
+		{name: "degree at 60 north", shape: MultiPolygon{square(0, 60, 1)}, want: 6123},
+		{name: "hole is subtracted", shape: MultiPolygon{{square(0, 0, 2)[0], square(0.5, 0.5, 1)[0]}}, want: 3 * 12364},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.shape.AreaKm2()
+			if math.Abs(got-tt.want)/tt.want > 0.01 {
+				t.Fatalf("AreaKm2() = %.0f, want about %.0f", got, tt.want)
+			}
+		})
+	}
+}
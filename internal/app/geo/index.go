@@ -0,0 +1,98 @@
+package geo
+
+import "sync"
+
+// RegionIndex - пространственный индекс границ регионов: R-дерево по рамкам
+// и точная проверка по самим полигонам
+type RegionIndex struct {
+	mu     sync.RWMutex
+	tree   *RTree
+	shapes map[int]MultiPolygon
+}
+
+func NewRegionIndex() *RegionIndex {
+	return &RegionIndex{tree: NewRTree(nil), shapes: map[int]MultiPolygon{}}
+}
+
+// Rebuild заменяет содержимое индекса целиком
+func (idx *RegionIndex) Rebuild(shapes map[int]MultiPolygon) {
+	entries := make([]RTreeEntry, 0, len(shapes))
+	for id, shape := range shapes {
+		entries = append(entries, RTreeEntry{Box: shape.BBox(), ID: id})
+	}
+	tree := NewRTree(entries)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.tree = tree
+	idx.shapes = shapes
+}
+
+// At возвращает id регионов, содержащих точку
+func (idx *RegionIndex) At(point Point) []int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	ids := []int{}
+	for _, id := range idx.tree.Search(BBox{MinLon: point.Lon, MinLat: point.Lat, MaxLon: point.Lon, MaxLat: point.Lat}) {
+		if idx.shapes[id].Contains(point) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// Within возвращает id регионов, пересекающихся с рамкой
+func (idx *RegionIndex) Within(box BBox) []int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	ids := []int{}
+	for _, id := range idx.tree.Search(box) {
+		if idx.shapes[id].IntersectsBox(box) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// AlongRoute возвращает id регионов, которые задевает коридор маршрута шириной width метров, в порядке пролёта
+func (idx *RegionIndex) AlongRoute(line LineString, width float64) []int {
+	halfWidth := width / 2
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	hits := []routeHit{}
+	for _, id := range idx.tree.Search(line.BBox().Buffer(halfWidth)) {
+		if segment, t, ok := idx.shapes[id].RouteEntry(line, halfWidth); ok {
+			hits = append(hits, routeHit{id: id, segment: segment, t: t})
+		}
+	}
+	sortRouteHits(hits)
+
+	ids := []int{}
+	for _, hit := range hits {
+		ids = append(ids, hit.id)
+	}
+
+	return ids
+}
+
+// Intersecting возвращает id регионов, имеющих общие точки с shape
+func (idx *RegionIndex) Intersecting(shape MultiPolygon) []int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	ids := []int{}
+	for _, id := range idx.tree.Search(shape.BBox()) {
+		if idx.shapes[id].Intersects(shape) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
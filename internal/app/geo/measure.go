@@ -0,0 +1,210 @@
+package geo
+
+import "math"
+
+// средний радиус Земли, км
+const earthRadiusKm = 6371.0088
+
+// AreaKm2 - площадь на сфере за вычетом дырок
+func (m MultiPolygon) AreaKm2() float64 {
+	area := 0.0
+	for _, polygon := range m {
+		for i, ring := range polygon {
+			ringKm2 := math.Abs(ringArea(ring)) * earthRadiusKm * earthRadiusKm
+			if i == 0 {
+				area += ringKm2
+			} else {
+				area -= ringKm2
+			}
+		}
+	}
+
+	return area
+}
+
+// ringArea - площадь контура на единичной сфере со знаком (Chamberlain, Duquette, "Some Algorithms for Polygons on a Sphere")
+func ringArea(ring []Point) float64 {
+	area := 0.0
+	for i := 0; i+1 < len(ring); i++ {
+		p1, p2 := ring[i], ring[i+1]
+		area += radians(p2.Lon-p1.Lon) * (2 + math.Sin(radians(p1.Lat)) + math.Sin(radians(p2.Lat)))
+	}
+
+	return area / 2
+}
+
+// Centroid - центр масс в координатах долгота/широта. Для регионов размером с город искажение проекции несущественно.
+func (m MultiPolygon) Centroid() Point {
+	var sumLon, sumLat, sumArea float64
+	for _, polygon := range m {
+		for i, ring := range polygon {
+			lon, lat, area := planarCentroid(ring)
+			if i > 0 {
+				area = -area
+			}
+			sumLon += lon * area
+			sumLat += lat * area
+			sumArea += area
+		}
+	}
+
+	if sumArea == 0 {
+		box := m.BBox()
+		return Point{Lon: (box.MinLon + box.MaxLon) / 2, Lat: (box.MinLat + box.MaxLat) / 2}
+	}
+
+	return Point{Lon: sumLon / sumArea, Lat: sumLat / sumArea}
+}
+
+// planarCentroid возвращает центр контура и модуль его площади на плоскости
+func planarCentroid(ring []Point) (float64, float64, float64) {
+	var cross, lon, lat float64
+	for i := 0; i+1 < len(ring); i++ {
+		p1, p2 := ring[i], ring[i+1]
+		c := p1.Lon*p2.Lat - p2.Lon*p1.Lat
+		cross += c
+		lon += (p1.Lon + p2.Lon) * c
+		lat += (p1.Lat + p2.Lat) * c
+	}
+
+	if cross == 0 {
+		return ring[0].Lon, ring[0].Lat, 0
+	}
+
+	return lon / (3 * cross), lat / (3 * cross), math.Abs(cross / 2)
+}
+
+func (m MultiPolygon) BBox() BBox {
+	box := BBox{MinLon: math.Inf(1), MinLat: math.Inf(1), MaxLon: math.Inf(-1), MaxLat: math.Inf(-1)}
+	for _, polygon := range m {
+		for _, point := range polygon[0] {
+			box.MinLon = math.Min(box.MinLon, point.Lon)
+			box.MinLat = math.Min(box.MinLat, point.Lat)
+			box.MaxLon = math.Max(box.MaxLon, point.Lon)
+			box.MaxLat = math.Max(box.MaxLat, point.Lat)
+		}
+	}
+
+	return box
+}
+
+func (b BBox) Intersects(other BBox) bool {
+	return b.MinLon <= other.MaxLon && other.MinLon <= b.MaxLon && b.MinLat <= other.MaxLat && other.MinLat <= b.MaxLat
+}
+
+func (b BBox) Contains(point Point) bool {
+	return b.MinLon <= point.Lon && point.Lon <= b.MaxLon && b.MinLat <= point.Lat && point.Lat <= b.MaxLat
+}
+
+// Contains - точка внутри внешнего контура одного из полигонов и не в его дырке
+func (m MultiPolygon) Contains(point Point) bool {
+	for _, polygon := range m {
+		if !ringContains(polygon[0], point) {
+			continue
+		}
+
+		inHole := false
+		for _, hole := range polygon[1:] {
+			if ringContains(hole, point) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IntersectsBox - есть ли у мультиполигона общие точки с рамкой
+func (m MultiPolygon) IntersectsBox(box BBox) bool {
+	if !m.BBox().Intersects(box) {
+		return false
+	}
+
+	corners := []Point{
+		{Lon: box.MinLon, Lat: box.MinLat},
+		{Lon: box.MaxLon, Lat: box.MinLat},
+		{Lon: box.MaxLon, Lat: box.MaxLat},
+		{Lon: box.MinLon, Lat: box.MaxLat},
+	}
+
+	// рамка целиком внутри полигона
+	if m.Contains(corners[0]) {
+		return true
+	}
+
+	for _, polygon := range m {
+		for _, ring := range polygon {
+			for i := 0; i+1 < len(ring); i++ {
+				// вершина внутри рамки или ребро пересекает её сторону
+				if box.Contains(ring[i]) {
+					return true
+				}
+				for j := range corners {
+					if segmentsIntersect(ring[i], ring[i+1], corners[j], corners[(j+1)%len(corners)]) {
+						return true
+					}
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// Intersects - есть ли у двух мультиполигонов общие точки: вершина одного внутри другого или пересекающиеся рёбра
+func (m MultiPolygon) Intersects(other MultiPolygon) bool {
+	if !m.BBox().Intersects(other.BBox()) {
+		return false
+	}
+
+	for _, polygon := range other {
+		if m.Contains(polygon[0][0]) {
+			return true
+		}
+	}
+	for _, polygon := range m {
+		if other.Contains(polygon[0][0]) {
+			return true
+		}
+	}
+
+	for _, polygon := range m {
+		for _, ring := range polygon {
+			for i := 0; i+1 < len(ring); i++ {
+				for _, otherPolygon := range other {
+					for _, otherRing := range otherPolygon {
+						for j := 0; j+1 < len(otherRing); j++ {
+							if segmentsIntersect(ring[i], ring[i+1], otherRing[j], otherRing[j+1]) {
+								return true
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// ringContains - проверка чётности пересечений луча с контуром
+func ringContains(ring []Point, point Point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Lat > point.Lat) != (b.Lat > point.Lat) &&
+			point.Lon < (b.Lon-a.Lon)*(point.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
@@ -0,0 +1,229 @@
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	maxRoutePoints = 1000
+	// MaxCorridorWidth - самый широкий коридор, который можно заказать, м
+	MaxCorridorWidth = 50000
+)
+
+// длина градуса меридиана, м
+const metersPerDegree = earthRadiusKm * 1000 * math.Pi / 180
+
+// LineString - маршрут полёта, точки в порядке пролёта
+type LineString []Point
+
+// ParseRoute разбирает и проверяет GeoJSON LineString
+func ParseRoute(data []byte) (LineString, error) {
+	var raw geoJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGeometry, err)
+	}
+
+	if raw.Type != "LineString" {
+		return nil, fmt.Errorf("%w: expected LineString, got %q", ErrInvalidGeometry, raw.Type)
+	}
+
+	var coordinates [][]float64
+	if err := json.Unmarshal(raw.Coordinates, &coordinates); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGeometry, err)
+	}
+
+	points := []Point{}
+	for _, position := range coordinates {
+		point := Point{Lon: math.NaN(), Lat: math.NaN()}
+		if len(position) >= 2 {
+			point = Point{Lon: position[0], Lat: position[1]}
+		}
+		points = append(points, point)
+	}
+
+	return NewRoute(points)
+}
+
+// NewRoute проверяет маршрут, заданный списком точек
+func NewRoute(points []Point) (LineString, error) {
+	if len(points) < 2 {
+		return nil, fmt.Errorf("%w: route must have at least 2 positions", ErrInvalidGeometry)
+	}
+	if len(points) > maxRoutePoints {
+		return nil, fmt.Errorf("%w: route has more than %d positions", ErrInvalidGeometry, maxRoutePoints)
+	}
+
+	moves := false
+	for i, point := range points {
+		if math.IsNaN(point.Lon) || math.IsNaN(point.Lat) || point.Lon < -180 || point.Lon > 180 || point.Lat < -90 || point.Lat > 90 {
+			return nil, fmt.Errorf("%w: route position %d is outside of [-180, 180] x [-90, 90]", ErrInvalidGeometry, i+1)
+		}
+		if point != points[0] {
+			moves = true
+		}
+	}
+
+	if !moves {
+		return nil, fmt.Errorf("%w: route has zero length", ErrInvalidGeometry)
+	}
+
+	return LineString(points), nil
+}
+
+// MarshalJSON сохраняет маршрут как GeoJSON LineString
+func (l LineString) MarshalJSON() ([]byte, error) {
+	coordinates := make([][2]float64, 0, len(l))
+	for _, point := range l {
+		coordinates = append(coordinates, [2]float64{point.Lon, point.Lat})
+	}
+
+	return json.Marshal(struct {
+		Type        string       `json:"type"`
+		Coordinates [][2]float64 `json:"coordinates"`
+	}{Type: "LineString", Coordinates: coordinates})
+}
+
+func (l LineString) BBox() BBox {
+	box := BBox{MinLon: math.Inf(1), MinLat: math.Inf(1), MaxLon: math.Inf(-1), MaxLat: math.Inf(-1)}
+	for _, point := range l {
+		box.MinLon = math.Min(box.MinLon, point.Lon)
+		box.MinLat = math.Min(box.MinLat, point.Lat)
+		box.MaxLon = math.Max(box.MaxLon, point.Lon)
+		box.MaxLat = math.Max(box.MaxLat, point.Lat)
+	}
+
+	return box
+}
+
+// Buffer расширяет рамку на meters во все стороны
+func (b BBox) Buffer(meters float64) BBox {
+	dLat := meters / metersPerDegree
+	// градус долготы короче всего на самой далёкой от экватора широте рамки
+	cos := math.Max(math.Cos(radians(math.Max(math.Abs(b.MinLat), math.Abs(b.MaxLat)))), 0.01)
+	dLon := dLat / cos
+
+	return BBox{MinLon: b.MinLon - dLon, MinLat: b.MinLat - dLat, MaxLon: b.MaxLon + dLon, MaxLat: b.MaxLat + dLat}
+}
+
+// RouteEntry находит место, где коридор шириной halfWidth метров по обе стороны маршрута впервые задевает
+// мультиполигон: номер отрезка маршрута и долю этого отрезка, пройденную до входа
+func (m MultiPolygon) RouteEntry(line LineString, halfWidth float64) (int, float64, bool) {
+	box := m.BBox()
+	for i := 0; i+1 < len(line); i++ {
+		if !box.Intersects(LineString{line[i], line[i+1]}.BBox().Buffer(halfWidth)) {
+			continue
+		}
+		if t, ok := m.segmentEntry(line[i], line[i+1], halfWidth); ok {
+			return i, t, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+// вектор в метрах на касательной плоскости
+type vec struct {
+	x float64
+	y float64
+}
+
+func (v vec) sub(other vec) vec       { return vec{x: v.x - other.x, y: v.y - other.y} }
+func (v vec) cross(other vec) float64 { return v.x*other.y - v.y*other.x }
+func (v vec) dot(other vec) float64   { return v.x*other.x + v.y*other.y }
+
+// project - равнопромежуточная проекция с центром в origin. На расстояниях в десятки километров ошибка незаметна.
+func project(origin Point, point Point) vec {
+	return vec{
+		x: (point.Lon - origin.Lon) * metersPerDegree * math.Cos(radians(origin.Lat)),
+		y: (point.Lat - origin.Lat) * metersPerDegree,
+	}
+}
+
+func (m MultiPolygon) segmentEntry(a Point, b Point, halfWidth float64) (float64, bool) {
+	if m.Contains(a) {
+		return 0, true
+	}
+
+	start, end := vec{}, project(a, b)
+	entry := math.Inf(1)
+
+	for _, polygon := range m {
+		for _, ring := range polygon {
+			for j := 0; j+1 < len(ring); j++ {
+				c, d := project(a, ring[j]), project(a, ring[j+1])
+
+				if t, ok := crossing(start, end, c, d); ok {
+					entry = math.Min(entry, t)
+				}
+
+				// ближе всего отрезки сходятся в одном из четырёх концов
+				if distanceToSegment(start, c, d) <= halfWidth {
+					entry = 0
+				}
+				if distanceToSegment(end, c, d) <= halfWidth {
+					entry = math.Min(entry, 1)
+				}
+				for _, p := range []vec{c, d} {
+					t := closestParameter(p, start, end)
+					closest := vec{x: start.x + (end.x-start.x)*t, y: start.y + (end.y-start.y)*t}
+					if math.Hypot(p.x-closest.x, p.y-closest.y) <= halfWidth {
+						entry = math.Min(entry, t)
+					}
+				}
+			}
+		}
+	}
+
+	return entry, !math.IsInf(entry, 1)
+}
+
+// crossing возвращает долю отрезка pq до точки пересечения с cd
+func crossing(p vec, q vec, c vec, d vec) (float64, bool) {
+	r, s := q.sub(p), d.sub(c)
+	denom := r.cross(s)
+	if denom == 0 {
+		// параллельные отрезки ловит проверка расстояний
+		return 0, false
+	}
+
+	t := c.sub(p).cross(s) / denom
+	u := c.sub(p).cross(r) / denom
+
+	return t, t >= 0 && t <= 1 && u >= 0 && u <= 1
+}
+
+func closestParameter(p vec, a vec, b vec) float64 {
+	ab := b.sub(a)
+	length := ab.dot(ab)
+	if length == 0 {
+		return 0
+	}
+
+	return math.Max(0, math.Min(1, p.sub(a).dot(ab)/length))
+}
+
+func distanceToSegment(p vec, a vec, b vec) float64 {
+	t := closestParameter(p, a, b)
+	return math.Hypot(p.x-(a.x+(b.x-a.x)*t), p.y-(a.y+(b.y-a.y)*t))
+}
+
+type routeHit struct {
+	id      int
+	segment int
+	t       float64
+}
+
+func sortRouteHits(hits []routeHit) {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].segment != hits[j].segment {
+			return hits[i].segment < hits[j].segment
+		}
+		if hits[i].t != hits[j].t {
+			return hits[i].t < hits[j].t
+		}
+		return hits[i].id < hits[j].id
+	})
+}
@@ -0,0 +1,178 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseRoute(t *testing.T) {
+	long := make([]string, maxRoutePoints+1)
+	for i := range long {
+		long[i] = fmt.Sprintf("[%f,0]", float64(i)*0.001)
+	}
+
+	tests := []struct {
+		name       string
+		data       string
+		wantPoints int
+		wantErr    bool
+	}{
+		{name: "line", data: `{"type":"LineString","coordinates":[[37.6,55.7],[37.7,55.8],[37.9,55.8]]}`, wantPoints: 3},
+		{name: "extra altitude is ignored", data: `{"type":"LineString","coordinates":[[37.6,55.7,120],[37.7,55.8,150]]}`, wantPoints: 2},
+		{name: "not json", data: `[`, wantErr: true},
+		{name: "polygon", data: `{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}`, wantErr: true},
+		{name: "single position", data: `{"type":"LineString","coordinates":[[37.6,55.7]]}`, wantErr: true},
+		{name: "zero length", data: `{"type":"LineString","coordinates":[[37.6,55.7],[37.6,55.7]]}`, wantErr: true},
+		{name: "out of range", data: `{"type":"LineString","coordinates":[[179,0],[181,0]]}`, wantErr: true},
+		{name: "position without latitude", data: `{"type":"LineString","coordinates":[[37.6,55.7],[37.7]]}`, wantErr: true},
+		{name: "too many positions", data: `{"type":"LineString","coordinates":[` + strings.Join(long, ",") + `]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, err := ParseRoute([]byte(tt.data))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidGeometry) {
+					t.Fatalf("ParseRoute() error = %v, want %v", err, ErrInvalidGeometry)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseRoute() error = %v", err)
+			}
+			if len(line) != tt.wantPoints {
+				t.Fatalf("ParseRoute() gave %d points, want %d", len(line), tt.wantPoints)
+			}
+		})
+	}
+}
+
+func TestLineStringMarshalJSON(t *testing.T) {
+	line := LineString{{Lon: 37.6, Lat: 55.7}, {Lon: 37.7, Lat: 55.8}}
+
+	data, err := json.Marshal(line)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	parsed, err := ParseRoute(data)
+	if err != nil {
+		t.Fatalf("ParseRoute(%s) error = %v", data, err)
+	}
+	if len(parsed) != len(line) || parsed[0] != line[0] || parsed[1] != line[1] {
+		t.Fatalf("round trip gave %v, want %v", parsed, line)
+	}
+}
+
+func TestBBoxBuffer(t *testing.T) {
+	tests := []struct {
+		name    string
+		box     BBox
+		meters  float64
+		wantLat float64
+		wantLon float64
+	}{
+		{name: "equator", box: BBox{}, meters: metersPerDegree, wantLat: 1, wantLon: 1},
+		{name: "60 north widens longitude twice", box: BBox{MinLat: 60, MaxLat: 60}, meters: metersPerDegree, wantLat: 1, wantLon: 2},
+		{name: "southern latitude counts the same", box: BBox{MinLat: -60, MaxLat: -10}, meters: metersPerDegree, wantLat: 1, wantLon: 2},
+		{name: "no buffer", box: BBox{MinLat: 10, MaxLat: 20}, meters: 0, wantLat: 0, wantLon: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.box.Buffer(tt.meters)
+			dLat, dLon := tt.box.MinLat-got.MinLat, tt.box.MinLon-got.MinLon
+			if math.Abs(dLat-tt.wantLat) > 1e-9 || math.Abs(dLon-tt.wantLon) > 1e-9 {
+				t.Fatalf("Buffer() grew by %v° lat, %v° lon, want %v°, %v°", dLat, dLon, tt.wantLat, tt.wantLon)
+			}
+			if got.MaxLat-tt.box.MaxLat != dLat || got.MaxLon-tt.box.MaxLon != dLon {
+				t.Fatalf("Buffer() is not symmetric: %+v", got)
+			}
+		})
+	}
+}
+
+func TestRouteEntry(t *testing.T) {
+	region := MultiPolygon{square(0, 0, 1)}
+	// 0.1° у экватора - около 11.1 км
+	tests := []struct {
+		name        string
+		line        LineString
+		halfWidth   float64
+		wantOK      bool
+		wantSegment int
+		wantT       float64
+	}{
+		{name: "crosses the edge halfway", line: LineString{{Lon: -1, Lat: 0.5}, {Lon: 1, Lat: 0.5}}, wantOK: true, wantT: 0.5},
+		{name: "starts inside", line: LineString{{Lon: 0.5, Lat: 0.5}, {Lon: 2, Lat: 0.5}}, wantOK: true, wantT: 0},
+		{
+			name:        "enters on the second segment",
+			line:        LineString{{Lon: -1, Lat: 5}, {Lon: -1, Lat: 0.5}, {Lon: 0.5, Lat: 0.5}},
+			wantOK:      true,
+			wantSegment: 1,
+			wantT:       1.0 / 1.5,
+		},
+		{name: "passes by", line: LineString{{Lon: -1, Lat: 1.1}, {Lon: 2, Lat: 1.1}}},
+		{name: "corridor too narrow", line: LineString{{Lon: -1, Lat: 0.5}, {Lon: -0.1, Lat: 0.5}}, halfWidth: 10000},
+		{name: "corridor reaches the region", line: LineString{{Lon: -1, Lat: 0.5}, {Lon: -0.1, Lat: 0.5}}, halfWidth: 12500, wantOK: true, wantT: 1},
+		{name: "corridor reaches a corner", line: LineString{{Lon: -1, Lat: 1.05}, {Lon: -0.05, Lat: 1.05}}, halfWidth: 10000, wantOK: true, wantT: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segment, entry, ok := region.RouteEntry(tt.line, tt.halfWidth)
+			if ok != tt.wantOK {
+				t.Fatalf("RouteEntry() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if segment != tt.wantSegment || math.Abs(entry-tt.wantT) > 1e-6 {
+				t.Fatalf("RouteEntry() = segment %d at %v, want segment %d at %v", segment, entry, tt.wantSegment, tt.wantT)
+			}
+		})
+	}
+}
+
+func TestAlongRoute(t *testing.T) {
+	index := NewRegionIndex()
+	index.Rebuild(map[int]MultiPolygon{
+		1: {square(0, 0, 1)},
+		2: {square(1, 0, 1)},
+		3: {square(2, 0, 1)},
+		4: {square(1, 1.1, 1)}, // севернее регионов 1-3 на 0.1°, около 11 км
+	})
+
+	tests := []struct {
+		name  string
+		line  LineString
+		width float64
+		want  []int
+	}{
+		{name: "in flight order", line: LineString{{Lon: -0.5, Lat: 0.5}, {Lon: 3.5, Lat: 0.5}}, want: []int{1, 2, 3}},
+		{name: "reversed", line: LineString{{Lon: 3.5, Lat: 0.5}, {Lon: -0.5, Lat: 0.5}}, want: []int{3, 2, 1}},
+		{name: "along the northern edge", line: LineString{{Lon: -0.5, Lat: 1}, {Lon: 1.5, Lat: 1}}, want: []int{1, 2}},
+		{name: "wide corridor catches the northern region", line: LineString{{Lon: -0.5, Lat: 0.9}, {Lon: 3.5, Lat: 0.9}}, width: 50000, want: []int{1, 2, 4, 3}},
+		{name: "turns back into a passed region", line: LineString{{Lon: 0.5, Lat: 0.5}, {Lon: 1.5, Lat: 0.5}, {Lon: 0.5, Lat: 0.6}}, want: []int{1, 2}},
+		{name: "misses everything", line: LineString{{Lon: 10, Lat: 10}, {Lon: 11, Lat: 11}}, want: []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := index.AlongRoute(tt.line, tt.width)
+			if len(got) != len(tt.want) {
+				t.Fatalf("AlongRoute() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("AlongRoute() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package geo
+
+import (
+	"math"
+	"sort"
+)
+
+// сколько детей у узла R-дерева
+const nodeCapacity = 16
+
+// RTree - статическое R-дерево, упакованное методом Sort-Tile-Recursive.
+// Регионы меняются редко, поэтому дерево не обновляется, а строится заново.
+type RTree struct {
+	root *rtreeNode
+}
+
+type RTreeEntry struct {
+	Box BBox
+	ID  int
+}
+
+type rtreeNode struct {
+	box      BBox
+	id       int
+	children []*rtreeNode // nil у листьев
+}
+
+func NewRTree(entries []RTreeEntry) *RTree {
+	if len(entries) == 0 {
+		return &RTree{}
+	}
+
+	level := make([]*rtreeNode, 0, len(entries))
+	for _, entry := range entries {
+		level = append(level, &rtreeNode{box: entry.Box, id: entry.ID})
+	}
+
+	for len(level) > 1 {
+		level = pack(level)
+	}
+
+	return &RTree{root: level[0]}
+}
+
+// pack собирает узлы уровня в родителей: режет на вертикальные полосы по долготе центра,
+// внутри полосы сортирует по широте и группирует по nodeCapacity
+func pack(nodes []*rtreeNode) []*rtreeNode {
+	parents := int(math.Ceil(float64(len(nodes)) / nodeCapacity))
+	slices := int(math.Ceil(math.Sqrt(float64(parents))))
+	sliceSize := slices * nodeCapacity
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return centerLon(nodes[i].box) < centerLon(nodes[j].box)
+	})
+
+	packed := []*rtreeNode{}
+	for start := 0; start < len(nodes); start += sliceSize {
+		slice := nodes[start:minInt(start+sliceSize, len(nodes))]
+		sort.Slice(slice, func(i, j int) bool {
+			return centerLat(slice[i].box) < centerLat(slice[j].box)
+		})
+
+		for from := 0; from < len(slice); from += nodeCapacity {
+			children := append([]*rtreeNode{}, slice[from:minInt(from+nodeCapacity, len(slice))]...)
+
+			box := children[0].box
+			for _, child := range children[1:] {
+				box = box.union(child.box)
+			}
+
+			packed = append(packed, &rtreeNode{box: box, children: children})
+		}
+	}
+
+	return packed
+}
+
+// Search возвращает id всех записей, рамки которых пересекаются с box
+func (t *RTree) Search(box BBox) []int {
+	ids := []int{}
+	if t.root == nil {
+		return ids
+	}
+
+	stack := []*rtreeNode{t.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if !node.box.Intersects(box) {
+			continue
+		}
+
+		if node.children == nil {
+			ids = append(ids, node.id)
+			continue
+		}
+
+		stack = append(stack, node.children...)
+	}
+
+	return ids
+}
+
+func (b BBox) union(other BBox) BBox {
+	return BBox{
+		MinLon: math.Min(b.MinLon, other.MinLon),
+		MinLat: math.Min(b.MinLat, other.MinLat),
+		MaxLon: math.Max(b.MaxLon, other.MaxLon),
+		MaxLat: math.Max(b.MaxLat, other.MaxLat),
+	}
+}
+
+func centerLon(box BBox) float64 {
+	return (box.MinLon + box.MaxLon) / 2
+}
+
+func centerLat(box BBox) float64 {
+	return (box.MinLat + box.MaxLat) / 2
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,102 @@
+package geo
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestRTreeSearch(t *testing.T) {
+	// сетка 40 x 40 клеток по 0.5°, чтобы дерево получилось в несколько уровней
+	entries := []RTreeEntry{}
+	for i := 0; i < 40; i++ {
+		for j := 0; j < 40; j++ {
+			lon, lat := float64(i)*0.5, float64(j)*0.5
+			entries = append(entries, RTreeEntry{Box: BBox{MinLon: lon, MinLat: lat, MaxLon: lon + 0.4, MaxLat: lat + 0.4}, ID: i*40 + j})
+		}
+	}
+	tree := NewRTree(append([]RTreeEntry{}, entries...))
+
+	tests := []struct {
+		name string
+		box  BBox
+	}{
+		{name: "single cell", box: BBox{MinLon: 0.1, MinLat: 0.1, MaxLon: 0.2, MaxLat: 0.2}},
+		{name: "gap between cells", box: BBox{MinLon: 0.42, MinLat: 0.42, MaxLon: 0.48, MaxLat: 0.48}},
+		{name: "touching cell borders", box: BBox{MinLon: 0.4, MinLat: 0.4, MaxLon: 0.5, MaxLat: 0.5}},
+		{name: "block of cells", box: BBox{MinLon: 3, MinLat: 5, MaxLon: 7.2, MaxLat: 6.1}},
+		{name: "everything", box: BBox{MinLon: -1, MinLat: -1, MaxLon: 30, MaxLat: 30}},
+		{name: "outside", box: BBox{MinLon: 50, MinLat: 50, MaxLon: 60, MaxLat: 60}},
+		{name: "point", box: BBox{MinLon: 10.2, MinLat: 10.2, MaxLon: 10.2, MaxLat: 10.2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := []int{}
+			for _, entry := range entries {
+				if entry.Box.Intersects(tt.box) {
+					want = append(want, entry.ID)
+				}
+			}
+
+			got := tree.Search(tt.box)
+			sort.Ints(got)
+			sort.Ints(want)
+
+			if len(got) != len(want) {
+				t.Fatalf("Search() found %d entries, want %d", len(got), len(want))
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("Search() = %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRTreeEmpty(t *testing.T) {
+	if got := NewRTree(nil).Search(BBox{MinLon: -180, MinLat: -90, MaxLon: 180, MaxLat: 90}); len(got) != 0 {
+		t.Fatalf("Search() on an empty tree = %v", got)
+	}
+}
+
+func TestRegionIndex(t *testing.T) {
+	index := NewRegionIndex()
+	index.Rebuild(map[int]MultiPolygon{
+		1: {square(0, 0, 10)},
+		2: {square(10, 0, 10)},
+		// треугольник, чья рамка накрывает точку (19, 19), а сам он - нет
+		3: {{{{20, 20}, {30, 20}, {20, 30}, {20, 20}}}},
+		4: {square(170, 60, 10), square(-180, 60, 10)},
+	})
+
+	tests := []struct {
+		name string
+		got  []int
+		want []int
+	}{
+		{name: "at a point inside", got: index.At(Point{Lon: 5, Lat: 5}), want: []int{1}},
+		{name: "at a point on the shared edge", got: index.At(Point{Lon: 10, Lat: 5}), want: []int{2}},
+		{name: "at a point in the bounding box only", got: index.At(Point{Lon: 29, Lat: 29}), want: []int{}},
+		{name: "at a point east of the antimeridian", got: index.At(Point{Lon: 179, Lat: 65}), want: []int{4}},
+		{name: "at a point west of the antimeridian", got: index.At(Point{Lon: -179, Lat: 65}), want: []int{4}},
+		{name: "within a box over two regions", got: index.Within(BBox{MinLon: 8, MinLat: 1, MaxLon: 12, MaxLat: 2}), want: []int{1, 2}},
+		{name: "within a box at the antimeridian", got: index.Within(BBox{MinLon: -175, MinLat: 62, MaxLon: -174, MaxLat: 63}), want: []int{4}},
+		{name: "intersecting a shape", got: index.Intersecting(MultiPolygon{square(9, 9, 2)}), want: []int{1, 2}},
+		{name: "intersecting a shape near a triangle", got: index.Intersecting(MultiPolygon{square(26, 26, 2)}), want: []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sort.Ints(tt.got)
+			if len(tt.got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", tt.got, tt.want)
+			}
+			for i := range tt.got {
+				if tt.got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", tt.got, tt.want)
+				}
+			}
+		})
+	}
+}
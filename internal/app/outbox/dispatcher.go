@@ -0,0 +1,142 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"drones/internal/app/config"
+	"drones/internal/app/ds"
+	"drones/internal/app/repository"
+)
+
+// Dispatcher отправляет сообщения из таблицы outbox во внешние сервисы.
+// Неудачные отправки повторяются с экспоненциальной паузой, пока не кончатся попытки.
+type Dispatcher struct {
+	repo      *repository.Repository
+	cfg       config.OutboxConfig
+	client    *http.Client
+	endpoints map[string]string
+}
+
+func New(repo *repository.Repository, cfg config.OutboxConfig) *Dispatcher {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 20
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = 10 * time.Second
+	}
+	if cfg.MaxBackoff < cfg.InitialBackoff {
+		cfg.MaxBackoff = cfg.InitialBackoff
+	}
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = 10 * time.Second
+	}
+
+	return &Dispatcher{
+		repo:   repo,
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.RequestTimeout},
+		endpoints: map[string]string{
+			ds.AllowedHoursTopic: cfg.AllowedHoursURL,
+		},
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.dispatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	// пока пачка отправляется, остальные экземпляры её не видят. Сообщения уходят по очереди,
+	// так что аренда должна пережить BatchSize запросов по RequestTimeout, и ещё один про запас.
+	lease := time.Duration(d.cfg.BatchSize+1) * d.cfg.RequestTimeout
+
+	messages, err := d.repo.ClaimOutboxMessages(time.Now(), d.cfg.BatchSize, lease)
+	if err != nil {
+		log.Println("outbox: can't claim messages:", err)
+		return
+	}
+
+	for _, message := range messages {
+		err := d.send(ctx, message)
+		if err == nil {
+			err = d.repo.MarkOutboxSent(message, time.Now())
+			if err != nil {
+				log.Printf("outbox: can't mark message %d as sent: %v", message.ID, err)
+			}
+			continue
+		}
+
+		attempts := message.Attempts + 1
+		dead := attempts >= d.cfg.MaxAttempts
+		if dead {
+			log.Printf("outbox: message %d moved to dead letter after %d attempts: %v", message.ID, attempts, err)
+		}
+
+		next_attempt := time.Now().Add(Backoff(attempts, d.cfg.InitialBackoff, d.cfg.MaxBackoff))
+		if err := d.repo.MarkOutboxFailed(message, next_attempt, err.Error(), dead); err != nil {
+			log.Printf("outbox: can't record failure of message %d: %v", message.ID, err)
+		}
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, message ds.OutboxMessage) error {
+	url, ok := d.endpoints[message.Topic]
+	if !ok || url == "" {
+		return fmt.Errorf("no endpoint configured for topic %q", message.Topic)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(message.Payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("%s responded %d: %s", url, response.StatusCode, body)
+	}
+
+	return nil
+}
+
+// Backoff - пауза перед следующей попыткой: initial, 2*initial, 4*initial... но не больше max
+func Backoff(attempts int, initial time.Duration, max time.Duration) time.Duration {
+	backoff := initial
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= max {
+			return max
+		}
+	}
+
+	return backoff
+}
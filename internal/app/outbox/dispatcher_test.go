@@ -0,0 +1,30 @@
+package outbox
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		want     time.Duration
+	}{
+		{name: "before the first attempt", attempts: 0, want: time.Second},
+		{name: "after the first failure", attempts: 1, want: time.Second},
+		{name: "doubles", attempts: 2, want: 2 * time.Second},
+		{name: "keeps doubling", attempts: 4, want: 8 * time.Second},
+		{name: "reaches max exactly", attempts: 5, want: 10 * time.Second},
+		{name: "capped by max", attempts: 6, want: 10 * time.Second},
+		{name: "does not overflow", attempts: 200, want: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Backoff(tt.attempts, time.Second, 10*time.Second); got != tt.want {
+				t.Fatalf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+			}
+		})
+	}
+}
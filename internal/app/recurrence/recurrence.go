@@ -0,0 +1,245 @@
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Поддерживается подмножество RFC 5545, которого хватает для регулярных полётов:
+// FREQ=DAILY|WEEKLY|MONTHLY, INTERVAL, COUNT, UNTIL и BYDAY (для DAILY и WEEKLY).
+
+type Frequency int
+
+const (
+	Daily Frequency = iota
+	Weekly
+	Monthly
+)
+
+// MaxOccurrences ограничивает размер одной серии
+const MaxOccurrences = 366
+
+var ErrUnbounded = errors.New("rrule must have COUNT or UNTIL")
+var ErrTooManyOccurrences = fmt.Errorf("rrule produces more than %d occurrences", MaxOccurrences)
+
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	Count    int
+	Until    time.Time
+	ByDay    []time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+func Parse(rule string) (*Rule, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+
+	result := &Rule{Interval: 1}
+	hasFreq := false
+
+	for _, part := range strings.Split(rule, ";") {
+		if part == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed rrule part %q", part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			hasFreq = true
+			switch strings.ToUpper(value) {
+			case "DAILY":
+				result.Freq = Daily
+			case "WEEKLY":
+				result.Freq = Weekly
+			case "MONTHLY":
+				result.Freq = Monthly
+			default:
+				return nil, fmt.Errorf("unsupported rrule FREQ %q", value)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("rrule INTERVAL must be a positive integer, got %q", value)
+			}
+			result.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("rrule COUNT must be a positive integer, got %q", value)
+			}
+			result.Count = count
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			result.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := weekdays[strings.ToUpper(day)]
+				if !ok {
+					return nil, fmt.Errorf("unsupported rrule BYDAY value %q", day)
+				}
+				result.ByDay = append(result.ByDay, weekday)
+			}
+		case "WKST":
+			// неделя всегда начинается с понедельника
+		default:
+			return nil, fmt.Errorf("unsupported rrule part %q", key)
+		}
+	}
+
+	if !hasFreq {
+		return nil, errors.New("rrule must have FREQ")
+	}
+
+	if result.Count == 0 && result.Until.IsZero() {
+		return nil, ErrUnbounded
+	}
+
+	if result.Count > 0 && !result.Until.IsZero() {
+		return nil, errors.New("rrule can't have both COUNT and UNTIL")
+	}
+
+	if result.Freq == Monthly && len(result.ByDay) > 0 {
+		return nil, errors.New("rrule BYDAY is not supported with FREQ=MONTHLY")
+	}
+
+	return result, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102", time.RFC3339} {
+		if until, err := time.Parse(layout, value); err == nil {
+			if layout == "20060102" {
+				until = until.Add(24*time.Hour - time.Second)
+			}
+			return until, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("malformed rrule UNTIL %q", value)
+}
+
+// Occurrences раскладывает правило начиная со start. Даты из exdates (по календарному дню) пропускаются,
+// но, как и в RFC 5545, учитываются в COUNT.
+func (r *Rule) Occurrences(start time.Time, exdates []time.Time) ([]time.Time, error) {
+	occurrences := []time.Time{}
+	generated := 0
+
+	for period := 0; ; period++ {
+		// на случай правила, которое почти ничего не порождает: UNTIL далеко, а подходящих дней нет
+		if period > 31*MaxOccurrences {
+			return nil, ErrTooManyOccurrences
+		}
+
+		candidates := r.periodCandidates(start, period)
+		if candidates == nil {
+			break
+		}
+
+		for _, candidate := range candidates {
+			if candidate.Before(start) {
+				continue
+			}
+			if !r.Until.IsZero() && candidate.After(r.Until) {
+				return occurrences, nil
+			}
+			if r.Count > 0 && generated >= r.Count {
+				return occurrences, nil
+			}
+
+			generated++
+			if generated > MaxOccurrences {
+				return nil, ErrTooManyOccurrences
+			}
+
+			if !excluded(candidate, exdates) {
+				occurrences = append(occurrences, candidate)
+			}
+		}
+	}
+
+	return occurrences, nil
+}
+
+func (r *Rule) periodCandidates(start time.Time, period int) []time.Time {
+	step := period * r.Interval
+
+	switch r.Freq {
+	case Daily:
+		day := start.AddDate(0, 0, step)
+		if len(r.ByDay) > 0 && !r.hasWeekday(day.Weekday()) {
+			return []time.Time{}
+		}
+		return []time.Time{day}
+	case Weekly:
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{start.Weekday()}
+		}
+
+		weekStart := start.AddDate(0, 0, -daysFromMonday(start.Weekday())+7*step)
+		candidates := []time.Time{}
+		for offset := 0; offset < 7; offset++ {
+			day := weekStart.AddDate(0, 0, offset)
+			for _, weekday := range days {
+				if day.Weekday() == weekday {
+					candidates = append(candidates, day)
+					break
+				}
+			}
+		}
+		return candidates
+	case Monthly:
+		day := start.AddDate(0, step, 0)
+		if day.Day() != start.Day() {
+			// в месяце нет такого числа (например 31-го), пропускаем его
+			return []time.Time{}
+		}
+		return []time.Time{day}
+	}
+
+	return nil
+}
+
+func (r *Rule) hasWeekday(weekday time.Weekday) bool {
+	for _, day := range r.ByDay {
+		if day == weekday {
+			return true
+		}
+	}
+
+	return false
+}
+
+func daysFromMonday(weekday time.Weekday) int {
+	return (int(weekday) + 6) % 7
+}
+
+func excluded(occurrence time.Time, exdates []time.Time) bool {
+	for _, exdate := range exdates {
+		exdate = exdate.In(occurrence.Location())
+		if exdate.Year() == occurrence.Year() && exdate.YearDay() == occurrence.YearDay() {
+			return true
+		}
+	}
+
+	return false
+}
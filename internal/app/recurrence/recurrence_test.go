@@ -0,0 +1,204 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 10, 0, 0, 0, time.UTC)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		want    Rule
+		wantErr error
+	}{
+		{
+			name: "daily with count",
+			rule: "FREQ=DAILY;COUNT=5",
+			want: Rule{Freq: Daily, Interval: 1, Count: 5},
+		},
+		{
+			name: "prefix, lower case and wkst",
+			rule: " RRULE:freq=weekly;interval=2;byday=mo,we;count=4;WKST=SU",
+			want: Rule{Freq: Weekly, Interval: 2, Count: 4, ByDay: []time.Weekday{time.Monday, time.Wednesday}},
+		},
+		{
+			name: "until as date covers the whole day",
+			rule: "FREQ=MONTHLY;UNTIL=20240301",
+			want: Rule{Freq: Monthly, Interval: 1, Until: time.Date(2024, 3, 1, 23, 59, 59, 0, time.UTC)},
+		},
+		{
+			name: "until as utc date-time",
+			rule: "FREQ=DAILY;UNTIL=20240301T120000Z",
+			want: Rule{Freq: Daily, Interval: 1, Until: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		},
+		{name: "no freq", rule: "COUNT=3"},
+		{name: "unbounded", rule: "FREQ=DAILY", wantErr: ErrUnbounded},
+		{name: "count and until", rule: "FREQ=DAILY;COUNT=3;UNTIL=20240301"},
+		{name: "unsupported freq", rule: "FREQ=YEARLY;COUNT=3"},
+		{name: "zero interval", rule: "FREQ=DAILY;INTERVAL=0;COUNT=3"},
+		{name: "negative count", rule: "FREQ=DAILY;COUNT=-1"},
+		{name: "unknown weekday", rule: "FREQ=WEEKLY;BYDAY=XX;COUNT=3"},
+		{name: "byday with monthly", rule: "FREQ=MONTHLY;BYDAY=MO;COUNT=3"},
+		{name: "unsupported part", rule: "FREQ=DAILY;COUNT=3;BYHOUR=10"},
+		{name: "malformed part", rule: "FREQ=DAILY;COUNT"},
+		{name: "malformed until", rule: "FREQ=DAILY;UNTIL=tomorrow"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+
+			wantOK := tt.want.Interval != 0
+			if !wantOK {
+				if err == nil {
+					t.Fatalf("Parse(%q) = %+v, want error", tt.rule, rule)
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Parse(%q) error = %v, want %v", tt.rule, err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.rule, err)
+			}
+			if rule.Freq != tt.want.Freq || rule.Interval != tt.want.Interval || rule.Count != tt.want.Count ||
+				!rule.Until.Equal(tt.want.Until) || !sameWeekdays(rule.ByDay, tt.want.ByDay) {
+				t.Fatalf("Parse(%q) = %+v, want %+v", tt.rule, *rule, tt.want)
+			}
+		})
+	}
+}
+
+func sameWeekdays(a []time.Weekday, b []time.Weekday) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestOccurrences(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		start   time.Time
+		exdates []time.Time
+		want    []time.Time
+	}{
+		{
+			name:  "daily",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: date(2024, 1, 1),
+			want:  []time.Time{date(2024, 1, 1), date(2024, 1, 2), date(2024, 1, 3)},
+		},
+		{
+			name:  "daily every other day filtered by weekday",
+			rule:  "FREQ=DAILY;INTERVAL=2;BYDAY=MO,WE,FR;COUNT=4",
+			start: date(2024, 1, 1), // понедельник
+			want:  []time.Time{date(2024, 1, 1), date(2024, 1, 3), date(2024, 1, 5), date(2024, 1, 15)},
+		},
+		{
+			name:  "weekly byday with interval skips whole weeks",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=4",
+			start: date(2024, 1, 3), // среда, понедельник этой недели уже прошёл
+			want:  []time.Time{date(2024, 1, 3), date(2024, 1, 15), date(2024, 1, 17), date(2024, 1, 29)},
+		},
+		{
+			name:  "weekly without byday repeats the start weekday",
+			rule:  "FREQ=WEEKLY;COUNT=3",
+			start: date(2024, 1, 4),
+			want:  []time.Time{date(2024, 1, 4), date(2024, 1, 11), date(2024, 1, 18)},
+		},
+		{
+			name:  "monthly on the 31st skips short months",
+			rule:  "FREQ=MONTHLY;COUNT=4",
+			start: date(2024, 1, 31),
+			want:  []time.Time{date(2024, 1, 31), date(2024, 3, 31), date(2024, 5, 31), date(2024, 7, 31)},
+		},
+		{
+			name:  "monthly with interval",
+			rule:  "FREQ=MONTHLY;INTERVAL=3;COUNT=3",
+			start: date(2024, 1, 15),
+			want:  []time.Time{date(2024, 1, 15), date(2024, 4, 15), date(2024, 7, 15)},
+		},
+		{
+			name:    "exdate is counted in count",
+			rule:    "FREQ=DAILY;COUNT=3",
+			start:   date(2024, 1, 1),
+			exdates: []time.Time{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+			want:    []time.Time{date(2024, 1, 1), date(2024, 1, 3)},
+		},
+		{
+			name:  "until is inclusive",
+			rule:  "FREQ=DAILY;UNTIL=20240103",
+			start: date(2024, 1, 1),
+			want:  []time.Time{date(2024, 1, 1), date(2024, 1, 2), date(2024, 1, 3)},
+		},
+		{
+			name:  "until before start",
+			rule:  "FREQ=DAILY;UNTIL=20231231",
+			start: date(2024, 1, 1),
+			want:  []time.Time{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.rule, err)
+			}
+
+			got, err := rule.Occurrences(tt.start, tt.exdates)
+			if err != nil {
+				t.Fatalf("Occurrences() error = %v", err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("Occurrences() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Fatalf("Occurrences() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestOccurrencesLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+	}{
+		{name: "count above the limit", rule: "FREQ=DAILY;COUNT=367"},
+		{name: "until too far", rule: "FREQ=DAILY;UNTIL=20300101"},
+		// с понедельника с шагом в 7 дней вторник не наступает никогда
+		{name: "until far with no matching days", rule: "FREQ=DAILY;INTERVAL=7;BYDAY=TU;UNTIL=20990101"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.rule, err)
+			}
+
+			if _, err := rule.Occurrences(date(2024, 1, 1), nil); !errors.Is(err, ErrTooManyOccurrences) {
+				t.Fatalf("Occurrences() error = %v, want %v", err, ErrTooManyOccurrences)
+			}
+		})
+	}
+}
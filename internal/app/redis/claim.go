@@ -0,0 +1,113 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const claimPrefix = "claim."
+
+var ErrClaimContended = errors.New("flight claim keeps changing hands")
+
+func getClaimKey(flightID int) string {
+	return servicePrefix + claimPrefix + strconv.Itoa(flightID)
+}
+
+// сколько раз пробовать захватить заявку, если чужой захват истекает прямо во время попытки
+const claimAttempts = 3
+
+// ClaimFlight закрепляет заявку за модератором на ttl. Повторный захват своим же модератором продлевает срок.
+// Если заявка уже закреплена за другим, возвращается false и текущий владелец.
+func (c *Client) ClaimFlight(ctx context.Context, flightID int, moderator uuid.UUID, ttl time.Duration) (uuid.UUID, bool, error) {
+	holder, ok, _, err := c.AutoClaimFlight(ctx, flightID, moderator, ttl)
+	return holder, ok, err
+}
+
+// AutoClaimFlight работает как ClaimFlight, но ещё сообщает, был ли захват новым, а не продлением своего.
+// Новый захват вызывающий должен снять сам, если решение не удалось.
+func (c *Client) AutoClaimFlight(ctx context.Context, flightID int, moderator uuid.UUID, ttl time.Duration) (uuid.UUID, bool, bool, error) {
+	for attempt := 0; attempt < claimAttempts; attempt++ {
+		acquired, err := c.client.SetNX(ctx, getClaimKey(flightID), moderator.String(), ttl).Result()
+		if err != nil {
+			return uuid.Nil, false, false, err
+		}
+		if acquired {
+			return moderator, true, true, nil
+		}
+
+		renewed, err := expireIfOwner.Run(ctx, c.client, []string{getClaimKey(flightID)}, moderator.String(), ttl.Milliseconds()).Int()
+		if err != nil {
+			return uuid.Nil, false, false, err
+		}
+		if renewed == 1 {
+			return moderator, true, false, nil
+		}
+
+		holder, err := c.GetFlightClaim(ctx, flightID)
+		if err != nil {
+			return uuid.Nil, false, false, err
+		}
+
+		// захват мог истечь между вызовами - тогда пробуем ещё раз
+		if holder != uuid.Nil {
+			return holder, false, false, nil
+		}
+	}
+
+	return uuid.Nil, false, false, ErrClaimContended
+}
+
+func (c *Client) ReleaseFlight(ctx context.Context, flightID int, moderator uuid.UUID) error {
+	return deleteIfOwner.Run(ctx, c.client, []string{getClaimKey(flightID)}, moderator.String()).Err()
+}
+
+// GetFlightClaim возвращает модератора, за которым закреплена заявка, или uuid.Nil
+func (c *Client) GetFlightClaim(ctx context.Context, flightID int) (uuid.UUID, error) {
+	holder, err := c.client.Get(ctx, getClaimKey(flightID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return uuid.Nil, nil
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return uuid.Parse(holder)
+}
+
+// GetFlightClaims возвращает захваты сразу для нескольких заявок, незахваченных в ответе нет
+func (c *Client) GetFlightClaims(ctx context.Context, flightIDs []int) (map[int]uuid.UUID, error) {
+	claims := map[int]uuid.UUID{}
+	if len(flightIDs) == 0 {
+		return claims, nil
+	}
+
+	keys := make([]string, 0, len(flightIDs))
+	for _, flightID := range flightIDs {
+		keys = append(keys, getClaimKey(flightID))
+	}
+
+	values, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, value := range values {
+		holder, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		holderUUID, err := uuid.Parse(holder)
+		if err != nil {
+			continue
+		}
+		claims[flightIDs[i]] = holderUUID
+	}
+
+	return claims, nil
+}
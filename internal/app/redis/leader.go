@@ -0,0 +1,32 @@
+package redis
+
+import (
+	"context"
+	"time"
+)
+
+const leaderPrefix = "leader."
+
+func getLeaderKey(name string) string {
+	return servicePrefix + leaderPrefix + name
+}
+
+// AcquireLeadership захватывает или продлевает лидерство instance в группе name.
+// Возвращает true, если после вызова лидером является instance.
+func (c *Client) AcquireLeadership(ctx context.Context, name string, instance string, ttl time.Duration) (bool, error) {
+	acquired, err := c.client.SetNX(ctx, getLeaderKey(name), instance, ttl).Result()
+	if err != nil || acquired {
+		return acquired, err
+	}
+
+	renewed, err := expireIfOwner.Run(ctx, c.client, []string{getLeaderKey(name)}, instance, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return renewed == 1, nil
+}
+
+func (c *Client) ReleaseLeadership(ctx context.Context, name string, instance string) error {
+	return deleteIfOwner.Run(ctx, c.client, []string{getLeaderKey(name)}, instance).Err()
+}
@@ -0,0 +1,17 @@
+package redis
+
+import (
+	"context"
+	"time"
+)
+
+const noncePrefix = "nonce."
+
+func getNonceKey(nonce string) string {
+	return servicePrefix + noncePrefix + nonce
+}
+
+// RememberNonce запоминает nonce на ttl. false - такой уже был, то есть запрос повторён.
+func (c *Client) RememberNonce(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, getNonceKey(nonce), true, ttl).Result()
+}
@@ -11,6 +11,22 @@ import (
 
 const servicePrefix = "drones-service."
 
+// продлевает ключ, только если он всё ещё принадлежит ARGV[1]
+var expireIfOwner = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// удаляет ключ, только если он всё ещё принадлежит ARGV[1]
+var deleteIfOwner = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
 type Client struct {
 	cfg    config.RedisConfig
 	client *redis.Client
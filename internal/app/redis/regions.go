@@ -0,0 +1,25 @@
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const regionsVersionKey = servicePrefix + "regions.version"
+
+// BumpRegionsVersion сообщает остальным экземплярам сервиса, что границы регионов изменились
+func (c *Client) BumpRegionsVersion(ctx context.Context) (int64, error) {
+	return c.client.Incr(ctx, regionsVersionKey).Result()
+}
+
+// GetRegionsVersion возвращает текущую версию границ регионов, 0 - их ещё не меняли
+func (c *Client) GetRegionsVersion(ctx context.Context) (int64, error) {
+	version, err := c.client.Get(ctx, regionsVersionKey).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+
+	return version, err
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"drones/internal/app/ds"
+)
+
+// checkCeilings сверяет верхнюю границу полёта с потолками регионов участков legs
+func (r *Repository) checkCeilings(tx *gorm.DB, legs []ds.FlightToRegion, max_altitude int) error {
+	region_ids := []int{}
+	for _, leg := range legs {
+		region_ids = append(region_ids, leg.RegionRefer)
+	}
+
+	if len(region_ids) == 0 {
+		return nil
+	}
+
+	regions := []ds.Region{}
+	err := tx.Select("id", "name", "max_altitude").
+		Where("id IN ?", region_ids).Where("max_altitude > 0").
+		Order("id").
+		Find(&regions).Error
+	if err != nil {
+		return err
+	}
+
+	return ds.CheckCeilings(max_altitude, regions)
+}
+
+func (r *Repository) flightCeilings(tx *gorm.DB, flight_id int) error {
+	flight := ds.Flight{}
+	if err := tx.Select("id", "max_altitude").First(&flight, "id = ?", flight_id).Error; err != nil {
+		return err
+	}
+
+	legs, err := r.flightLegs(tx, flight_id)
+	if err != nil {
+		return err
+	}
+
+	return r.checkCeilings(tx, legs, flight.MaxAltitude)
+}
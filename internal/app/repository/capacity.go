@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"drones/internal/app/ds"
+)
+
+// какие заявки занимают место над регионом
+var capacityStatuses = []string{ds.Completed.String(), ds.InFlight.String()}
+
+type timeWindow struct {
+	from time.Time
+	to   time.Time
+}
+
+// checkCapacity проверяет, что одобрение заявки не превысит MaxConcurrentFlights ни в одном из её регионов
+// в то время, когда над ним проходит соответствующий участок.
+// Регионы блокируются до конца транзакции, чтобы два модератора не заняли последнее место одновременно,
+// поэтому занятость считается заново уже после блокировки: прочитанное до неё могло устареть.
+func (r *Repository) checkCapacity(tx *gorm.DB, flight_id int) error {
+	flight := ds.Flight{}
+	if err := tx.First(&flight, "id = ?", flight_id).Error; err != nil {
+		return err
+	}
+
+	legs, err := r.flightLegs(tx, flight_id)
+	if err != nil {
+		return err
+	}
+
+	region_ids := []int{}
+	for _, leg := range legs {
+		region_ids = append(region_ids, leg.RegionRefer)
+	}
+
+	regions := []ds.Region{}
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", region_ids).
+		Where("max_concurrent_flights > 0").
+		Order("id").
+		Find(&regions).Error
+	if err != nil {
+		return err
+	}
+
+	if len(regions) == 0 {
+		return nil
+	}
+
+	limited := map[uint]ds.Region{}
+	for _, region := range regions {
+		limited[region.ID] = region
+	}
+
+	conflicts, err := r.flightConflicts(tx, flight_id)
+	if err != nil {
+		return err
+	}
+
+	for _, leg := range legs {
+		region, ok := limited[uint(leg.RegionRefer)]
+		if !ok {
+			continue
+		}
+
+		windows := []timeWindow{}
+		for _, conflict := range conflicts {
+			if conflict.RegionID != region.ID || !holdsCapacity(conflict.Status) {
+				continue
+			}
+
+			windows = append(windows, timeWindow{from: conflict.TakeoffDate, to: conflict.ArrivalDate})
+		}
+
+		from, to := ds.LegWindow(leg, flight.TakeoffDate, flight.ArrivalDate)
+		occupied, slice := peakOccupancy(windows, timeWindow{from: from, to: to})
+		if occupied >= region.MaxConcurrentFlights {
+			return &ds.CapacityError{
+				RegionID:   region.ID,
+				RegionName: region.Name,
+				Capacity:   region.MaxConcurrentFlights,
+				Occupied:   occupied,
+				From:       slice.from,
+				To:         slice.to,
+			}
+		}
+	}
+
+	return nil
+}
+
+func holdsCapacity(status string) bool {
+	for _, capacityStatus := range capacityStatuses {
+		if capacityStatus == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// peakOccupancy находит наибольшее число окон, одновременно открытых внутри bounds, и отрезок, где оно достигается.
+// Окна полуоткрытые: полёт, который приземлился ровно в момент взлёта другого, с ним не пересекается.
+func peakOccupancy(windows []timeWindow, bounds timeWindow) (int, timeWindow) {
+	type edge struct {
+		at    time.Time
+		delta int
+	}
+
+	edges := []edge{}
+	for _, window := range windows {
+		from, to := window.from, window.to
+		if from.Before(bounds.from) {
+			from = bounds.from
+		}
+		if to.After(bounds.to) {
+			to = bounds.to
+		}
+		if !from.Before(to) {
+			continue
+		}
+
+		edges = append(edges, edge{at: from, delta: 1}, edge{at: to, delta: -1})
+	}
+
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].at.Equal(edges[j].at) {
+			return edges[i].delta < edges[j].delta
+		}
+		return edges[i].at.Before(edges[j].at)
+	})
+
+	peak, current := 0, 0
+	slice := bounds
+	for i, e := range edges {
+		current += e.delta
+		if current > peak {
+			peak = current
+			slice = timeWindow{from: e.at, to: bounds.to}
+			if i+1 < len(edges) {
+				slice.to = edges[i+1].at
+			}
+		}
+	}
+
+	return peak, slice
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func window(from int, to int) timeWindow {
+	return timeWindow{
+		from: time.Date(2024, 1, 1, from, 0, 0, 0, time.UTC),
+		to:   time.Date(2024, 1, 1, to, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestPeakOccupancy(t *testing.T) {
+	tests := []struct {
+		name      string
+		windows   []timeWindow
+		bounds    timeWindow
+		wantPeak  int
+		wantSlice timeWindow
+	}{
+		{name: "no flights", windows: nil, bounds: window(10, 14), wantPeak: 0, wantSlice: window(10, 14)},
+		{name: "one flight inside", windows: []timeWindow{window(11, 12)}, bounds: window(10, 14), wantPeak: 1, wantSlice: window(11, 12)},
+		{
+			name:      "overlap of two",
+			windows:   []timeWindow{window(10, 12), window(11, 13)},
+			bounds:    window(10, 14),
+			wantPeak:  2,
+			wantSlice: window(11, 12),
+		},
+		{
+			name:      "landing at takeoff of another is not an overlap",
+			windows:   []timeWindow{window(10, 12), window(12, 14)},
+			bounds:    window(10, 14),
+			wantPeak:  1,
+			wantSlice: window(10, 12),
+		},
+		{
+			name:      "flights outside bounds are ignored",
+			windows:   []timeWindow{window(8, 10), window(14, 16), window(11, 12)},
+			bounds:    window(10, 14),
+			wantPeak:  1,
+			wantSlice: window(11, 12),
+		},
+		{
+			name:      "windows are clipped to bounds",
+			windows:   []timeWindow{window(8, 11), window(9, 16)},
+			bounds:    window(10, 14),
+			wantPeak:  2,
+			wantSlice: window(10, 11),
+		},
+		{
+			name:      "three nested",
+			windows:   []timeWindow{window(10, 14), window(11, 13), window(12, 13)},
+			bounds:    window(10, 14),
+			wantPeak:  3,
+			wantSlice: window(12, 13),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peak, slice := peakOccupancy(tt.windows, tt.bounds)
+			if peak != tt.wantPeak {
+				t.Fatalf("peakOccupancy() peak = %d, want %d", peak, tt.wantPeak)
+			}
+			if !slice.from.Equal(tt.wantSlice.from) || !slice.to.Equal(tt.wantSlice.to) {
+				t.Fatalf("peakOccupancy() slice = %v - %v, want %v - %v", slice.from, slice.to, tt.wantSlice.from, tt.wantSlice.to)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"drones/internal/app/ds"
+	"drones/internal/app/role"
+)
+
+// CloneFlight копирует участки и длительность заявки в черновик пользователя со сдвигом на takeoff_date.
+// У пользователя может быть только один черновик (на это рассчитывает GetDraftFlight):
+// если он уже есть, то при merge регионы дописываются в него, иначе возвращается ds.ErrDraftExists.
+// При дописывании окно черновика меняется на окно копии, поэтому время участков сбрасывается:
+// старые участки в новое окно не попадают, а без времени участок занимает регион на весь полёт.
+func (r *Repository) CloneFlight(source_id int, takeoff_date time.Time, userUUID uuid.UUID, userRole role.Role, merge bool) (ds.Flight, error) {
+	draft := ds.Flight{}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// блокировка пользователя выстраивает его копирования в очередь, иначе оба не найдут черновик и создадут по своему
+		if err := tx.Exec("SELECT 1 FROM users WHERE uuid = ? FOR UPDATE", userUUID).Error; err != nil {
+			return err
+		}
+
+		source := ds.Flight{}
+		if err := tx.First(&source, "id = ?", source_id).Error; err != nil {
+			return err
+		}
+
+		if userRole == role.User && (source.UserRefer == nil || *source.UserRefer != userUUID) {
+			return ds.ErrFlightNotOwned
+		}
+
+		arrival_date := takeoff_date.Add(source.ArrivalDate.Sub(source.TakeoffDate))
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_refer = ?", userUUID).Where("status = ?", ds.Draft.String()).
+			Limit(1).Find(&draft).Error
+		if err != nil {
+			return err
+		}
+
+		if draft.ID != 0 && !merge {
+			return ds.ErrDraftExists
+		}
+
+		if draft.ID != 0 {
+			err = tx.Model(&ds.Flight{}).Where("id = ?", draft.ID).Updates(map[string]interface{}{
+				"takeoff_date":  takeoff_date,
+				"arrival_date":  arrival_date,
+				"version":       nextVersion,
+				"date_modified": time.Now(),
+			}).Error
+			if err != nil {
+				return err
+			}
+
+			// к черновику дописываются чужие регионы, его маршрут им больше не соответствует
+			if err := dropRoute(tx, int(draft.ID)); err != nil {
+				return err
+			}
+
+			draft.TakeoffDate = takeoff_date
+			draft.ArrivalDate = arrival_date
+		} else {
+			draft = ds.Flight{
+				UserRefer:     &userUUID,
+				Status:        ds.Draft.String(),
+				DateCreated:   time.Now(),
+				TakeoffDate:   takeoff_date,
+				ArrivalDate:   arrival_date,
+				Route:         source.Route,
+				CorridorWidth: source.CorridorWidth,
+				MinAltitude:   source.MinAltitude,
+				MaxAltitude:   source.MaxAltitude,
+			}
+			err = tx.Omit("moderator_refer", "date_processed", "date_finished").Create(&draft).Error
+			if err != nil {
+				return draftError(err)
+			}
+
+			err = r.writeStatusEvent(tx, int(draft.ID), "", ds.Draft, userUUID, userRole, "")
+			if err != nil {
+				return err
+			}
+		}
+
+		existing, err := r.flightLegs(tx, int(draft.ID))
+		if err != nil {
+			return err
+		}
+
+		legs, err := r.flightLegs(tx, source_id)
+		if err != nil {
+			return err
+		}
+
+		// время участков переносим, только если черновик пустой: иначе участки не сойдутся в одно окно
+		if len(existing) == 0 {
+			legs = ds.ShiftLegs(legs, takeoff_date.Sub(source.TakeoffDate))
+		} else {
+			err = tx.Model(&ds.FlightToRegion{}).Where("flight_refer = ?", draft.ID).
+				Updates(map[string]interface{}{"entry_date": nil, "exit_date": nil}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		present := map[int]bool{}
+		for _, leg := range existing {
+			present[leg.RegionRefer] = true
+		}
+
+		appended := []ds.FlightToRegion{}
+		for _, leg := range legs {
+			if present[leg.RegionRefer] {
+				continue
+			}
+			present[leg.RegionRefer] = true
+
+			leg.Sequence = len(existing) + len(appended) + 1
+			if len(existing) != 0 {
+				leg.EntryDate = nil
+				leg.ExitDate = nil
+			}
+			appended = append(appended, leg)
+		}
+
+		if err := createLegs(tx, int(draft.ID), appended); err != nil {
+			return err
+		}
+
+		merged, err := r.flightLegs(tx, int(draft.ID))
+		if err != nil {
+			return err
+		}
+
+		return ds.ValidateLegs(merged, draft.TakeoffDate, draft.ArrivalDate)
+	})
+
+	return draft, err
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"drones/internal/app/ds"
+)
+
+// с какими заявками вообще можно пересечься
+var conflictingStatuses = []string{ds.Formed.String(), ds.Completed.String(), ds.InFlight.String()}
+
+// окно чужого участка: если у участка нет своего времени, он занимает регион на весь полёт
+const (
+	legEntrySQL = "COALESCE(flight_to_regions.entry_date, flights.takeoff_date)"
+	legExitSQL  = "COALESCE(flight_to_regions.exit_date, flights.arrival_date)"
+)
+
+// findConflicts ищет чужие участки в тех же регионах, что и legs, пересекающиеся с ними по времени.
+// В найденных конфликтах TakeoffDate/ArrivalDate - время, когда чужой полёт находится над регионом.
+func (r *Repository) findConflicts(tx *gorm.DB, flight_id int, legs []ds.FlightToRegion, takeoff_date time.Time, arrival_date time.Time) ([]ds.FlightConflict, error) {
+	conflicts := []ds.FlightConflict{}
+
+	if takeoff_date.IsZero() || arrival_date.IsZero() {
+		return conflicts, nil
+	}
+
+	type conflictKey struct {
+		flightID uint
+		regionID uint
+		from     time.Time
+	}
+	seen := map[conflictKey]bool{}
+
+	for _, leg := range legs {
+		from, to := ds.LegWindow(leg, takeoff_date, arrival_date)
+
+		found := []ds.FlightConflict{}
+		err := tx.Table("flight_to_regions").
+			Select("flights.id AS flight_id, flights.status, "+legEntrySQL+" AS takeoff_date, "+legExitSQL+" AS arrival_date, regions.id AS region_id, regions.name AS region_name, regions.block_on_conflict AS blocking").
+			Joins("JOIN flights ON flights.id = flight_to_regions.flight_refer").
+			Joins("JOIN regions ON regions.id = flight_to_regions.region_refer").
+			Where("flight_to_regions.region_refer = ?", leg.RegionRefer).
+			Where("flights.id <> ?", flight_id).
+			Where("flights.status IN ?", conflictingStatuses).
+			Where(legEntrySQL+" < ? AND "+legExitSQL+" > ?", to, from).
+			Order("takeoff_date, flights.id").
+			Scan(&found).Error
+		if err != nil {
+			return nil, err
+		}
+
+		for _, conflict := range found {
+			key := conflictKey{flightID: conflict.FlightID, regionID: conflict.RegionID, from: conflict.TakeoffDate}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			conflicts = append(conflicts, conflict)
+		}
+	}
+
+	return conflicts, nil
+}
+
+// lockBlockingRegions блокирует до конца транзакции регионы участков, где пересечения запрещены,
+// чтобы две заявки не заняли одно окно, пока каждая проверяет пересечения без другой
+func lockBlockingRegions(tx *gorm.DB, legs []ds.FlightToRegion) error {
+	region_ids := []int{}
+	for _, leg := range legs {
+		region_ids = append(region_ids, leg.RegionRefer)
+	}
+	if len(region_ids) == 0 {
+		return nil
+	}
+
+	regions := []ds.Region{}
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+		Where("id IN ?", region_ids).Where("block_on_conflict = ?", true).
+		Order("id").Find(&regions).Error
+}
+
+func (r *Repository) flightConflicts(tx *gorm.DB, flight_id int) ([]ds.FlightConflict, error) {
+	flight := ds.Flight{}
+	if err := tx.First(&flight, "id = ?", flight_id).Error; err != nil {
+		return nil, err
+	}
+
+	legs, err := r.flightLegs(tx, flight_id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.findConflicts(tx, flight_id, legs, flight.TakeoffDate, flight.ArrivalDate)
+}
+
+func (r *Repository) GetFlightConflicts(flight_id int) ([]ds.FlightConflict, error) {
+	return r.flightConflicts(r.db, flight_id)
+}
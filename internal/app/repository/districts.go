@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"drones/internal/app/ds"
+	"drones/internal/app/geo"
+	"drones/internal/app/role"
+)
+
+// resolveDistrict находит округ, к которому привязывают регион: по DistrictRefer или по названию District.
+// Если переданы оба, они должны указывать на один округ. Без них регион остаётся без округа.
+func (r *Repository) resolveDistrict(region *ds.Region) error {
+	if region.District == "" && region.DistrictRefer == nil {
+		return nil
+	}
+
+	district := ds.District{}
+	tx := r.db.Select("id", "name")
+	if region.District != "" {
+		tx = tx.Where("name = ?", region.District)
+	}
+	if region.DistrictRefer != nil {
+		tx = tx.Where("id = ?", *region.DistrictRefer)
+	}
+
+	err := tx.First(&district).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ds.ErrUnknownDistrict
+	}
+	if err != nil {
+		return err
+	}
+
+	region.DistrictRefer = &district.ID
+	region.District = district.Name
+
+	return nil
+}
+
+// fillDistrictNames проставляет регионам названия округов одним запросом
+func (r *Repository) fillDistrictNames(regions []ds.Region) error {
+	ids := []uint{}
+	for _, region := range regions {
+		if region.DistrictRefer != nil {
+			ids = append(ids, *region.DistrictRefer)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	districts := []ds.District{}
+	if err := r.db.Select("id", "name").Where("id IN ?", ids).Find(&districts).Error; err != nil {
+		return err
+	}
+
+	names := map[uint]string{}
+	for _, district := range districts {
+		names[district.ID] = district.Name
+	}
+
+	for i := range regions {
+		if regions[i].DistrictRefer != nil {
+			regions[i].District = names[*regions[i].DistrictRefer]
+		}
+	}
+
+	return nil
+}
+
+// checkDistrictModerator пускает модератора только к заявкам над его округами. Если ни за один округ на маршруте
+// никто не отвечает, заявку решает любой модератор; администратора проверка не касается.
+func checkDistrictModerator(tx *gorm.DB, flight_id int, moderator uuid.UUID, moderatorRole role.Role) error {
+	if moderatorRole != role.Moderator {
+		return nil
+	}
+
+	responsible := []uuid.UUID{}
+	err := tx.Table("district_moderators").
+		Joins("JOIN regions ON regions.district_refer = district_moderators.district_refer").
+		Joins("JOIN flight_to_regions ON flight_to_regions.region_refer = regions.id").
+		Where("flight_to_regions.flight_refer = ?", flight_id).
+		Distinct().
+		Pluck("district_moderators.moderator_refer", &responsible).Error
+	if err != nil {
+		return err
+	}
+
+	if len(responsible) == 0 {
+		return nil
+	}
+
+	for _, id := range responsible {
+		if id == moderator {
+			return nil
+		}
+	}
+
+	return ds.ErrNotDistrictModerator
+}
+
+func (r *Repository) CheckDistrictModerator(flight_id int, moderator uuid.UUID, moderatorRole role.Role) error {
+	return checkDistrictModerator(r.db, flight_id, moderator, moderatorRole)
+}
+
+// GetDistricts возвращает округа с числом действующих регионов и заявок, ждущих модерации
+func (r *Repository) GetDistricts() ([]ds.DistrictSummary, error) {
+	summaries := []ds.DistrictSummary{}
+
+	err := r.db.Table("districts").
+		Select("districts.id, districts.name, "+
+			"(SELECT COUNT(*) FROM regions WHERE regions.district_refer = districts.id AND regions.status <> ?) AS region_count, "+
+			"(SELECT COUNT(DISTINCT flight_to_regions.flight_refer) FROM flight_to_regions "+
+			"JOIN regions ON regions.id = flight_to_regions.region_refer "+
+			"JOIN flights ON flights.id = flight_to_regions.flight_refer "+
+			"WHERE regions.district_refer = districts.id AND flights.status = ?) AS pending_flights",
+			ds.UnavailableRegionStatus, ds.Formed.String()).
+		Order("districts.name").
+		Scan(&summaries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	type moderatorRow struct {
+		DistrictRefer uint
+		Name          string
+	}
+
+	rows := []moderatorRow{}
+	err = r.db.Table("district_moderators").
+		Select("district_moderators.district_refer, users.name").
+		Joins("JOIN users ON users.uuid = district_moderators.moderator_refer").
+		Order("users.name").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	moderators := map[uint][]string{}
+	for _, row := range rows {
+		moderators[row.DistrictRefer] = append(moderators[row.DistrictRefer], row.Name)
+	}
+
+	for i := range summaries {
+		summaries[i].Moderators = moderators[summaries[i].ID]
+		if summaries[i].Moderators == nil {
+			summaries[i].Moderators = []string{}
+		}
+	}
+
+	return summaries, nil
+}
+
+func (r *Repository) GetDistrict(district_id int) (ds.District, error) {
+	district := ds.District{}
+	err := r.db.First(&district, "id = ?", district_id).Error
+
+	return district, err
+}
+
+func (r *Repository) GetDistrictModerators(district_id int) ([]string, error) {
+	names := []string{}
+
+	err := r.db.Table("district_moderators").
+		Joins("JOIN users ON users.uuid = district_moderators.moderator_refer").
+		Where("district_moderators.district_refer = ?", district_id).
+		Order("users.name").
+		Pluck("users.name", &names).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+func (r *Repository) GetDistrictRegions(district_id int) ([]ds.Region, error) {
+	regions := []ds.Region{}
+
+	err := r.db.Where("district_refer = ?", district_id).Order("name").Find(&regions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.fillDistrictNames(regions); err != nil {
+		return nil, err
+	}
+
+	return regions, nil
+}
+
+// CreateDistrict создаёт округ и назначает ему модераторов по логинам
+func (r *Repository) CreateDistrict(district *ds.District, moderators []string) error {
+	if err := validateDistrictBoundary(district); err != nil {
+		return err
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(district).Error; err != nil {
+			return districtError(err)
+		}
+
+		return setDistrictModerators(tx, int(district.ID), moderators)
+	})
+}
+
+// EditDistrict меняет непустые поля округа; moderators = nil оставляет модераторов как есть
+func (r *Repository) EditDistrict(district *ds.District, moderators []string) error {
+	if err := validateDistrictBoundary(district); err != nil {
+		return err
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&ds.District{}).Where("id = ?", district.ID).Updates(district)
+		if result.Error != nil {
+			return districtError(result.Error)
+		}
+
+		var count int64
+		if err := tx.Model(&ds.District{}).Where("id = ?", district.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if moderators == nil {
+			return nil
+		}
+
+		return setDistrictModerators(tx, int(district.ID), moderators)
+	})
+}
+
+// districtError переводит нарушение уникальности названия в ошибку ds
+func districtError(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ds.ErrDistrictExists
+	}
+
+	return err
+}
+
+func validateDistrictBoundary(district *ds.District) error {
+	if len(district.Boundary) == 0 {
+		return nil
+	}
+
+	_, err := geo.ParseBoundary(district.Boundary)
+	return err
+}
+
+// setDistrictModerators заменяет модераторов округа. Назначить можно только модератора или администратора.
+func setDistrictModerators(tx *gorm.DB, district_id int, logins []string) error {
+	if err := tx.Where("district_refer = ?", district_id).Delete(&ds.DistrictModerator{}).Error; err != nil {
+		return err
+	}
+
+	seen := map[uuid.UUID]bool{}
+	for _, login := range logins {
+		user := ds.User{}
+		err := tx.Where("name = ?", login).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %q", ds.ErrNotModerator, login)
+		}
+		if err != nil {
+			return err
+		}
+
+		if user.Role != role.Moderator && user.Role != role.Admin {
+			return fmt.Errorf("%w: %q", ds.ErrNotModerator, login)
+		}
+
+		if seen[user.UUID] {
+			continue
+		}
+		seen[user.UUID] = true
+
+		moderator := user.UUID
+		link := ds.DistrictModerator{DistrictRefer: district_id, ModeratorRefer: &moderator}
+		if err := tx.Omit("District", "Moderator").Create(&link).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"drones/internal/app/ds"
+	"drones/internal/app/role"
+)
+
+// ReportTakeoff отмечает фактический взлёт одобренной заявки. Взлететь можно только в одобренное окно.
+func (r *Repository) ReportTakeoff(flight_id int, at time.Time, actor uuid.UUID, actorRole role.Role) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		flight := ds.Flight{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&flight, "id = ?", flight_id).Error
+		if err != nil {
+			return err
+		}
+
+		// чужой заявке не стоит подсказывать, в какое окно она летает
+		if actorRole == role.User && (flight.UserRefer == nil || *flight.UserRefer != actor) {
+			return ds.ErrFlightNotOwned
+		}
+
+		if at.Before(flight.TakeoffDate) || !at.Before(flight.ArrivalDate) {
+			return ds.ErrOutsideFlightWindow
+		}
+
+		return r.transitionFlight(tx, flight_id, ds.InFlight, actor, actorRole, "", map[string]interface{}{
+			"actual_takeoff": at,
+		})
+	})
+}
+
+// ReportLanding отмечает фактическую посадку. Поздняя посадка допускается, но флаг Overrun остаётся.
+func (r *Repository) ReportLanding(flight_id int, at time.Time, actor uuid.UUID, actorRole role.Role) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		flight := ds.Flight{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&flight, "id = ?", flight_id).Error
+		if err != nil {
+			return err
+		}
+
+		if actorRole == role.User && (flight.UserRefer == nil || *flight.UserRefer != actor) {
+			return ds.ErrFlightNotOwned
+		}
+
+		if flight.ActualTakeoff != nil && !at.After(*flight.ActualTakeoff) {
+			return ds.ErrOutsideFlightWindow
+		}
+
+		reason := ""
+		if at.After(flight.ArrivalDate) {
+			reason = fmt.Sprintf("посадка позже плана на %s", at.Sub(flight.ArrivalDate).Round(time.Minute))
+		}
+
+		return r.transitionFlight(tx, flight_id, ds.Landed, actor, actorRole, reason, map[string]interface{}{
+			"actual_landing": at,
+		})
+	})
+}
+
+// DetectOverruns помечает полёты, которые так и не сообщили о посадке к времени прилёта,
+// и уведомляет одобривших их модераторов. Ошибка по одному полёту не мешает остальным, ошибки собираются вместе.
+func (r *Repository) DetectOverruns(now time.Time) (int, error) {
+	flights := []ds.Flight{}
+
+	err := r.db.Where("status = ?", ds.InFlight.String()).Where("arrival_date < ?", now).Where("overrun = ?", false).
+		Find(&flights).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	done := 0
+	for _, flight := range flights {
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&ds.Flight{}).
+				Where("id = ?", flight.ID).Where("status = ?", ds.InFlight.String()).Where("overrun = ?", false).
+				Updates(map[string]interface{}{"overrun": true, "version": nextVersion})
+			if result.Error != nil || result.RowsAffected == 0 || flight.ModeratorRefer == nil {
+				return result.Error
+			}
+
+			return r.notify(tx, *flight.ModeratorRefer, int(flight.ID), fmt.Sprintf("Полёт по заявке %d не сообщил о посадке к %s", flight.ID, flight.ArrivalDate.Format(time.RFC3339)))
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		done++
+	}
+
+	return done, errors.Join(errs...)
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"drones/internal/app/ds"
+	"drones/internal/app/role"
+)
+
+const (
+	expiredReason    = "Заявка не была рассмотрена до времени взлёта"
+	staleDraftReason = "Черновик давно не редактировался"
+	finishedReason   = "Время полёта истекло"
+)
+
+// expireFlights переводит подходящие заявки в статус to от имени системы.
+// Каждая заявка переводится в своей транзакции, ошибки по отдельным заявкам собираются вместе.
+func (r *Repository) expireFlights(flights []ds.Flight, to ds.FlightStatus, reason string, updates map[string]interface{}) (int, error) {
+	var errs []error
+	done := 0
+
+	for _, flight := range flights {
+		// transitionFlight дописывает в updates статус, поэтому каждой заявке своя копия
+		flight_updates := map[string]interface{}{}
+		for column, value := range updates {
+			flight_updates[column] = value
+		}
+
+		err := r.changeFlightStatus(int(flight.ID), to, uuid.Nil, role.System, reason, flight_updates)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		done++
+	}
+
+	return done, errors.Join(errs...)
+}
+
+// RejectUnmoderatedFlights отклоняет сформированные заявки, время взлёта которых уже прошло
+func (r *Repository) RejectUnmoderatedFlights(now time.Time) (int, error) {
+	flights := []ds.Flight{}
+
+	err := r.db.Where("status = ?", ds.Formed.String()).Where("takeoff_date < ?", now).Find(&flights).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return r.expireFlights(flights, ds.Rejected, expiredReason, map[string]interface{}{
+		"date_processed":   now,
+		"rejection_code":   ds.ExpiredRejectionCode,
+		"rejection_reason": expiredReason,
+	})
+}
+
+// PurgeStaleDrafts удаляет черновики, которые не меняли с olderThan.
+// У нетронутых после создания черновиков date_modified пустое или нулевое, тогда берётся date_created.
+func (r *Repository) PurgeStaleDrafts(olderThan time.Time) (int, error) {
+	flights := []ds.Flight{}
+
+	err := r.db.Where("status = ?", ds.Draft.String()).Where("GREATEST(date_created, date_modified) < ?", olderThan).Find(&flights).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return r.expireFlights(flights, ds.Deleted, staleDraftReason, nil)
+}
+
+// FinishFlights помечает одобренные заявки выполненными после времени прилёта
+func (r *Repository) FinishFlights(now time.Time) (int, error) {
+	flights := []ds.Flight{}
+
+	err := r.db.Where("status = ?", ds.Completed.String()).Where("arrival_date < ?", now).Find(&flights).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return r.expireFlights(flights, ds.Finished, finishedReason, nil)
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+
+	"drones/internal/app/ds"
+	"drones/internal/app/geo"
+)
+
+// applyBoundary проверяет границу региона и пересчитывает по ней площадь, центр и рамку.
+// Регион без границы остаётся как есть, AreaKm тогда задаётся вручную.
+func applyBoundary(region *ds.Region) error {
+	if len(region.Boundary) == 0 {
+		return nil
+	}
+
+	boundary, err := geo.ParseBoundary(region.Boundary)
+	if err != nil {
+		return err
+	}
+
+	centroid := boundary.Centroid()
+	box := boundary.BBox()
+
+	region.AreaKm = json.Number(strconv.FormatFloat(boundary.AreaKm2(), 'f', 3, 64))
+	region.CentroidLon = centroid.Lon
+	region.CentroidLat = centroid.Lat
+	region.BBoxMinLon = box.MinLon
+	region.BBoxMinLat = box.MinLat
+	region.BBoxMaxLon = box.MaxLon
+	region.BBoxMaxLat = box.MaxLat
+
+	return nil
+}
+
+// GetRegionShapes возвращает границы действующих регионов для пространственного индекса
+func (r *Repository) GetRegionShapes() (map[int]geo.MultiPolygon, error) {
+	regions := []ds.Region{}
+
+	err := r.db.Select("id", "boundary").
+		Where("boundary IS NOT NULL").Where("status <> ?", ds.UnavailableRegionStatus).
+		Find(&regions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	shapes := map[int]geo.MultiPolygon{}
+	for _, region := range regions {
+		shape, err := geo.ParseBoundary(region.Boundary)
+		if err != nil {
+			// граница проверяется при записи, так что сюда попадают только данные, внесённые в обход API
+			log.Printf("region %d has invalid boundary: %v", region.ID, err)
+			continue
+		}
+		shapes[int(region.ID)] = shape
+	}
+
+	return shapes, nil
+}
+
+func (r *Repository) GetRegionsByIDs(ids []int) ([]ds.Region, error) {
+	regions := []ds.Region{}
+	if len(ids) == 0 {
+		return regions, nil
+	}
+
+	err := r.db.Where("id IN ?", ids).Order("name").Find(&regions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.fillDistrictNames(regions); err != nil {
+		return nil, err
+	}
+
+	return regions, nil
+}
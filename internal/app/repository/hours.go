@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"strconv"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"drones/internal/app/ds"
+)
+
+// AllowedHoursProvider рассчитывает разрешённые часы заявки, которую отправили на модерацию.
+// Вызывается в транзакции подтверждения: реализация либо сразу записывает часы,
+// либо ставит запрос во внешний сервис, который пришлёт их обратным вызовом.
+type AllowedHoursProvider interface {
+	RequestAllowedHours(tx *gorm.DB, flight ds.Flight) error
+}
+
+// ServiceHoursProvider отдаёт расчёт сервису drones-async через outbox
+type ServiceHoursProvider struct{}
+
+func (ServiceHoursProvider) RequestAllowedHours(tx *gorm.DB, flight ds.Flight) error {
+	return enqueue(tx, ds.AllowedHoursTopic, ds.AllowedHoursPayload{PK: strconv.Itoa(int(flight.ID))})
+}
+
+// LocalHoursProvider считает часы сам по правилам регионов: над регионом можно находиться,
+// пока он действует, открыт (Region.OpensAt/ClosesAt) и не закрыт блокирующим ограничением полётов,
+// вне своего участка регион полёт не ограничивает.
+type LocalHoursProvider struct {
+	repo     *Repository
+	location *time.Location // в каком часовом поясе заданы часы работы регионов
+}
+
+func NewLocalHoursProvider(repo *Repository, location *time.Location) *LocalHoursProvider {
+	return &LocalHoursProvider{repo: repo, location: location}
+}
+
+func (p *LocalHoursProvider) RequestAllowedHours(tx *gorm.DB, flight ds.Flight) error {
+	allowed, err := p.AllowedHours(tx, flight)
+	if err != nil {
+		return err
+	}
+
+	return tx.Model(&ds.Flight{}).Where("id = ?", flight.ID).Updates(map[string]interface{}{
+		"allowed_hours": datatypes.NewJSONSlice(allowed),
+		"version":       nextVersion,
+	}).Error
+}
+
+func (p *LocalHoursProvider) AllowedHours(tx *gorm.DB, flight ds.Flight) ([]ds.TimeInterval, error) {
+	legs, err := p.repo.flightLegs(tx, int(flight.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	hits, err := p.repo.findRestrictions(tx, flight, legs)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := []ds.TimeInterval{{From: flight.TakeoffDate, To: flight.ArrivalDate}}
+	for _, leg := range legs {
+		region := ds.Region{}
+		if err := tx.First(&region, "id = ?", leg.RegionRefer).Error; err != nil {
+			return nil, err
+		}
+
+		from, to := ds.LegWindow(leg, flight.TakeoffDate, flight.ArrivalDate)
+
+		permitted := []ds.TimeInterval{
+			{From: flight.TakeoffDate, To: from},
+			{From: to, To: flight.ArrivalDate},
+		}
+		if region.Status != ds.UnavailableRegionStatus {
+			closed := []ds.TimeInterval{}
+			for _, hit := range ds.BlockingRestrictions(hits) {
+				if hit.RegionID == uint(leg.RegionRefer) {
+					closed = append(closed, ds.TimeInterval{From: hit.StartsAt, To: hit.EndsAt})
+				}
+			}
+
+			open := ds.OperatingHours(from, to, region.OpensAt, region.ClosesAt, p.location)
+			permitted = append(permitted, ds.SubtractIntervals(open, closed)...)
+		}
+
+		allowed = ds.IntersectIntervals(allowed, permitted)
+	}
+
+	return allowed, nil
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"drones/internal/app/ds"
+)
+
+// resolveLegs собирает участки полёта из запроса. Если участки не переданы,
+// каждый регион из списка становится участком без времени, как было до появления участков.
+func (r *Repository) resolveLegs(requestLegs []ds.FlightLegRequest, regions []string) ([]ds.FlightToRegion, error) {
+	legs := []ds.FlightToRegion{}
+
+	if len(requestLegs) == 0 {
+		seen := map[int]bool{}
+		for _, name := range regions {
+			region_id, err := r.GetRegionID(name)
+			if err != nil {
+				return nil, err
+			}
+
+			if seen[region_id] {
+				continue
+			}
+			seen[region_id] = true
+
+			legs = append(legs, ds.FlightToRegion{RegionRefer: region_id, Sequence: len(legs) + 1})
+		}
+
+		return legs, nil
+	}
+
+	for i, requestLeg := range requestLegs {
+		region_id, err := r.GetRegionID(requestLeg.Region)
+		if err != nil {
+			return nil, err
+		}
+
+		leg := ds.FlightToRegion{RegionRefer: region_id, Sequence: i + 1}
+
+		if requestLeg.EntryDate != "" {
+			entry_date, err := time.Parse(time.RFC3339, requestLeg.EntryDate)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ds.ErrInvalidLegs, err)
+			}
+			leg.EntryDate = &entry_date
+		}
+
+		if requestLeg.ExitDate != "" {
+			exit_date, err := time.Parse(time.RFC3339, requestLeg.ExitDate)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ds.ErrInvalidLegs, err)
+			}
+			leg.ExitDate = &exit_date
+		}
+
+		legs = append(legs, leg)
+	}
+
+	return legs, nil
+}
+
+func (r *Repository) flightLegs(tx *gorm.DB, flight_id int) ([]ds.FlightToRegion, error) {
+	legs := []ds.FlightToRegion{}
+
+	err := tx.Where("flight_refer = ?", flight_id).Order("sequence, id").Find(&legs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return legs, nil
+}
+
+func createLegs(tx *gorm.DB, flight_id int, legs []ds.FlightToRegion) error {
+	for _, leg := range legs {
+		leg.ID = 0
+		leg.FlightRefer = flight_id
+
+		if err := tx.Omit("Flight", "Region").Create(&leg).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renumberLegs сдвигает Sequence участков к 1..n без пропусков
+func renumberLegs(tx *gorm.DB, legs []ds.FlightToRegion) error {
+	for i := range legs {
+		if legs[i].Sequence == i+1 {
+			continue
+		}
+
+		legs[i].Sequence = i + 1
+		if err := tx.Model(&ds.FlightToRegion{}).Where("id = ?", legs[i].ID).Update("sequence", legs[i].Sequence).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) GetFlightLegs(flight_id int) ([]ds.FlightLeg, error) {
+	legs, err := r.flightLegs(r.db, flight_id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []ds.FlightLeg{}
+	for _, leg := range legs {
+		region, err := r.GetRegionByID(leg.RegionRefer)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, ds.FlightLeg{
+			Sequence:  leg.Sequence,
+			Region:    region.Name,
+			EntryDate: leg.EntryDate,
+			ExitDate:  leg.ExitDate,
+		})
+	}
+
+	return result, nil
+}
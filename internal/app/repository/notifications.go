@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"drones/internal/app/ds"
+)
+
+func (r *Repository) notify(tx *gorm.DB, user uuid.UUID, flight_id int, message string) error {
+	notification := ds.Notification{
+		UserRefer:   &user,
+		FlightRefer: &flight_id,
+		Message:     message,
+		DateCreated: time.Now(),
+	}
+
+	return tx.Omit("User").Create(&notification).Error
+}
+
+func (r *Repository) GetNotifications(user uuid.UUID, unreadOnly bool) ([]ds.Notification, error) {
+	notifications := []ds.Notification{}
+
+	query := r.db.Where("user_refer = ?", user)
+	if unreadOnly {
+		query = query.Where("date_read IS NULL")
+	}
+
+	err := query.Order("date_created DESC, id DESC").Find(&notifications).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func (r *Repository) MarkNotificationRead(notification_id int, user uuid.UUID) error {
+	result := r.db.Model(&ds.Notification{}).
+		Where("id = ?", notification_id).Where("user_refer = ?", user).
+		Update("date_read", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"drones/internal/app/ds"
+)
+
+func enqueue(tx *gorm.DB, topic string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	message := ds.OutboxMessage{
+		Topic:         topic,
+		Payload:       data,
+		Status:        ds.OutboxPending,
+		NextAttemptAt: now,
+		DateCreated:   now,
+	}
+
+	return tx.Create(&message).Error
+}
+
+// ClaimOutboxMessages забирает готовые к отправке сообщения и откладывает их на lease,
+// чтобы другие экземпляры сервиса не отправили их одновременно с нами.
+// Каждое забранное сообщение помечается новым LeaseToken: по нему Mark* проверяют, что аренда ещё наша.
+func (r *Repository) ClaimOutboxMessages(now time.Time, limit int, lease time.Duration) ([]ds.OutboxMessage, error) {
+	messages := []ds.OutboxMessage{}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", ds.OutboxPending).Where("next_attempt_at <= ?", now).
+			Order("id").Limit(limit).Find(&messages).Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		ids := []uint{}
+		for _, message := range messages {
+			ids = append(ids, message.ID)
+		}
+
+		token := uuid.NewString()
+		for i := range messages {
+			messages[i].LeaseToken = token
+		}
+
+		return tx.Model(&ds.OutboxMessage{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"next_attempt_at": now.Add(lease),
+			"lease_token":     token,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// MarkOutboxSent отмечает сообщение отправленным, если аренда ещё наша, иначе возвращает ds.ErrOutboxLeaseLost
+func (r *Repository) MarkOutboxSent(message ds.OutboxMessage, at time.Time) error {
+	return updateLeased(r.db, message, map[string]interface{}{
+		"status":      ds.OutboxSent,
+		"attempts":    gorm.Expr("attempts + 1"),
+		"date_sent":   at,
+		"last_error":  "",
+		"lease_token": "",
+	})
+}
+
+// MarkOutboxFailed записывает неудачную попытку. Если dead, сообщение больше не отправляется.
+func (r *Repository) MarkOutboxFailed(message ds.OutboxMessage, next_attempt time.Time, lastError string, dead bool) error {
+	status := ds.OutboxPending
+	if dead {
+		status = ds.OutboxDead
+	}
+
+	return updateLeased(r.db, message, map[string]interface{}{
+		"status":          status,
+		"attempts":        gorm.Expr("attempts + 1"),
+		"next_attempt_at": next_attempt,
+		"last_error":      lastError,
+		"lease_token":     "",
+	})
+}
+
+func updateLeased(tx *gorm.DB, message ds.OutboxMessage, updates map[string]interface{}) error {
+	result := tx.Model(&ds.OutboxMessage{}).
+		Where("id = ?", message.ID).Where("status = ?", ds.OutboxPending).Where("lease_token = ?", message.LeaseToken).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ds.ErrOutboxLeaseLost
+	}
+
+	return nil
+}
+
+func (r *Repository) GetDeadLetters() ([]ds.OutboxMessage, error) {
+	messages := []ds.OutboxMessage{}
+
+	err := r.db.Where("status = ?", ds.OutboxDead).Order("id").Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// RequeueOutboxMessage возвращает сообщение из dead letter в очередь с обнулёнными попытками
+func (r *Repository) RequeueOutboxMessage(id int) error {
+	result := r.db.Model(&ds.OutboxMessage{}).Where("id = ?", id).Where("status = ?", ds.OutboxDead).Updates(map[string]interface{}{
+		"status":          ds.OutboxPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+		"lease_token":     "",
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -1,12 +1,15 @@
 package repository
 
 import (
-	"log"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/datatypes"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"drones/internal/app/ds"
 	"drones/internal/app/role"
@@ -17,7 +20,8 @@ type Repository struct {
 }
 
 func New(dsn string) (*Repository, error) {
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// TranslateError превращает нарушения уникальности в gorm.ErrDuplicatedKey, см. draftError
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, err
 	}
@@ -49,6 +53,17 @@ func (r *Repository) GetRegionByID(id int) (*ds.Region, error) {
 	return region, nil
 }
 
+func (r *Repository) GetFlightByID(id int) (*ds.Flight, error) {
+	flight := &ds.Flight{}
+
+	err := r.db.First(flight, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return flight, nil
+}
+
 func (r *Repository) GetUserByID(id uuid.UUID) (*ds.User, error) {
 	user := &ds.User{}
 
@@ -60,6 +75,26 @@ func (r *Repository) GetUserByID(id uuid.UUID) (*ds.User, error) {
 	return user, nil
 }
 
+// GetUserNames возвращает имена пользователей одним запросом
+func (r *Repository) GetUserNames(ids []uuid.UUID) (map[uuid.UUID]string, error) {
+	names := map[uuid.UUID]string{}
+	if len(ids) == 0 {
+		return names, nil
+	}
+
+	users := []ds.User{}
+	err := r.db.Where("uuid IN ?", ids).Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		names[user.UUID] = user.Name
+	}
+
+	return names, nil
+}
+
 func (r *Repository) GetUserByLogin(login string) (*ds.User, error) {
 	user := &ds.User{}
 
@@ -125,7 +160,7 @@ func (r *Repository) GetRegions(name_pattern string, district string, status str
 	}
 
 	if district != "" {
-		tx = tx.Where("district = ?", district)
+		tx = tx.Where("district_refer IN (?)", r.db.Model(&ds.District{}).Select("id").Where("name = ?", district))
 	}
 	if status != "" {
 		tx = tx.Where("status = ?", status)
@@ -137,10 +172,14 @@ func (r *Repository) GetRegions(name_pattern string, district string, status str
 		return nil, err
 	}
 
+	if err := r.fillDistrictNames(regions); err != nil {
+		return nil, err
+	}
+
 	return regions, nil
 }
 
-func (r *Repository) GetFlights(status string, startDate string, endDate string, roleNumber role.Role, userUUID uuid.UUID) ([]ds.Flight, error) {
+func (r *Repository) GetFlights(status string, startDate string, endDate string, overrunOnly bool, roleNumber role.Role, userUUID uuid.UUID) ([]ds.Flight, error) {
 	flights := []ds.Flight{}
 
 	var tx *gorm.DB = r.db
@@ -156,6 +195,10 @@ func (r *Repository) GetFlights(status string, startDate string, endDate string,
 		tx = tx.Where("date_created <= ?", endDate)
 	}
 
+	if overrunOnly {
+		tx = tx.Where("overrun = ?", true)
+	}
+
 	if roleNumber == role.User {
 		tx = tx.Where("user_refer = ?", userUUID)
 	}
@@ -166,6 +209,12 @@ func (r *Repository) GetFlights(status string, startDate string, endDate string,
 		return nil, err
 	}
 
+	r.fillFlightUsers(flights)
+
+	return flights, nil
+}
+
+func (r *Repository) fillFlightUsers(flights []ds.Flight) {
 	for i := range flights {
 		if flights[i].ModeratorRefer != nil {
 			moderator, _ := r.GetUserByID(*flights[i].ModeratorRefer)
@@ -174,19 +223,25 @@ func (r *Repository) GetFlights(status string, startDate string, endDate string,
 		user, _ := r.GetUserByID(*flights[i].UserRefer)
 		flights[i].User = *user
 	}
-
-	return flights, nil
 }
 
 func (r *Repository) GetDraftFlight(user uuid.UUID) (ds.Flight, error) {
 	flight := ds.Flight{}
 
-	err := r.db.Where("user_refer = ?", user).Where("status = ?", "Черновик").Find(&flight).Error
+	err := r.db.Where("user_refer = ?", user).Where("status = ?", ds.Draft.String()).Find(&flight).Error
 
 	return flight, err
 }
 
 func (r *Repository) CreateRegion(region ds.Region) error {
+	if err := applyBoundary(&region); err != nil {
+		return err
+	}
+
+	if err := r.resolveDistrict(&region); err != nil {
+		return err
+	}
+
 	return r.db.Create(&region).Error
 }
 
@@ -194,12 +249,68 @@ func (r *Repository) CreateUser(user ds.User) error {
 	return r.db.Create(&user).Error
 }
 
-func (r *Repository) CreateFlight(flight ds.Flight) error {
-	return r.db.Create(&flight).Error
+// draftError - уникальный индекс idx_flights_one_draft не даёт завести пользователю второй черновик
+func draftError(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ds.ErrDraftExists
+	}
+
+	return err
 }
 
+func (r *Repository) CreateFlight(flight ds.Flight, actorRole role.Role) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&flight).Error; err != nil {
+			return draftError(err)
+		}
+
+		status, err := ds.ParseFlightStatus(flight.Status)
+		if err != nil {
+			return err
+		}
+
+		return r.writeStatusEvent(tx, int(flight.ID), "", status, *flight.UserRefer, actorRole, "")
+	})
+}
+
+// CreateFlightToRegion дописывает участок в конец полёта. Участок без времени нельзя добавить к участкам со временем,
+// поэтому после добавления участки проверяются заново.
 func (r *Repository) CreateFlightToRegion(flight_to_region ds.FlightToRegion) error {
-	return r.db.Create(&flight_to_region).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		flight := ds.Flight{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&flight, "id = ?", flight_to_region.FlightRefer).Error; err != nil {
+			return err
+		}
+
+		if flight_to_region.Sequence == 0 {
+			var last int
+			err := tx.Model(&ds.FlightToRegion{}).Where("flight_refer = ?", flight_to_region.FlightRefer).
+				Select("COALESCE(MAX(sequence), 0)").Scan(&last).Error
+			if err != nil {
+				return err
+			}
+			flight_to_region.Sequence = last + 1
+		}
+
+		if err := tx.Create(&flight_to_region).Error; err != nil {
+			return err
+		}
+
+		legs, err := r.flightLegs(tx, flight_to_region.FlightRefer)
+		if err != nil {
+			return err
+		}
+
+		if err := ds.ValidateLegs(legs, flight.TakeoffDate, flight.ArrivalDate); err != nil {
+			return err
+		}
+
+		if err := dropRoute(tx, flight_to_region.FlightRefer); err != nil {
+			return err
+		}
+
+		return bumpFlightVersion(tx, flight_to_region.FlightRefer)
+	})
 }
 
 func (r *Repository) LogicalDeleteRegion(region_name string) error {
@@ -210,7 +321,7 @@ func (r *Repository) LogicalDeleteRegion(region_name string) error {
 		}
 	}()
 
-	if err := tx.Exec(`UPDATE public.regions SET status = ? WHERE name = ?`, "Недоступен", region_name).Error; err != nil {
+	if err := tx.Exec(`UPDATE public.regions SET status = ?, version = version + 1 WHERE name = ?`, ds.UnavailableRegionStatus, region_name).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -218,7 +329,15 @@ func (r *Repository) LogicalDeleteRegion(region_name string) error {
 	return tx.Commit().Error
 }
 
-func (r *Repository) LogicalDeleteFlight(flight_id int) error {
+func (r *Repository) LogicalDeleteFlight(flight_id int, actor uuid.UUID, actorRole role.Role) error {
+	return r.changeFlightStatus(flight_id, ds.Deleted, actor, actorRole, "", nil)
+}
+
+// ModConfirmFlight возвращает найденные пересечения с другими заявками, чтобы модератор их видел.
+// Одобрение не пройдёт, если заявка пересекается с уже одобренной в регионе с запретом пересечений
+// или если над одним из регионов не останется места (Region.MaxConcurrentFlights),
+// или если заявка поднимается выше потолка региона или задевает блокирующее ограничение полётов.
+func (r *Repository) ModConfirmFlight(uuid uuid.UUID, moderatorRole role.Role, flight_id int, decision ds.ModerationDecision) ([]ds.FlightConflict, error) {
 	tx := r.db.Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -226,55 +345,104 @@ func (r *Repository) LogicalDeleteFlight(flight_id int) error {
 		}
 	}()
 
-	if err := tx.Exec(`UPDATE public.flights SET status = ? WHERE id = ?`, "Удалён", flight_id).Error; err != nil {
+	if err := checkFlightVersion(tx, flight_id, decision.Version); err != nil {
 		tx.Rollback()
-		return err
+		return nil, err
 	}
 
-	return tx.Commit().Error
-}
+	if err := checkDistrictModerator(tx, flight_id, uuid, moderatorRole); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 
-func (r *Repository) ModConfirmFlight(uuid uuid.UUID, flight_id int, confirm bool) error {
-	tx := r.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
+	legs, err := r.flightLegs(tx, flight_id)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := lockBlockingRegions(tx, legs); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	conflicts, err := r.flightConflicts(tx, flight_id)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"moderator_refer":   uuid,
+		"date_processed":    time.Now(),
+		"moderator_comment": decision.Comment,
+		"rejection_code":    decision.Code,
+		"rejection_reason":  decision.Reason,
+	}
+	reason := decision.Code + ": " + decision.Reason
+
+	new_status := ds.Rejected
+	if decision.Confirm {
+		if blocking := ds.BlockingConflicts(conflicts); len(blocking) > 0 {
 			tx.Rollback()
+			return conflicts, &ds.ConflictError{Conflicts: blocking}
 		}
-	}()
 
-	new_status := "Отклонён"
-	if confirm {
-		new_status = "Завершён"
-		if err := tx.Exec(`UPDATE public.flights SET status = ?, moderator_refer = ?, date_processed = ?, date_finished = ? WHERE id = ?`, new_status, uuid, time.Now(), time.Now(), flight_id).Error; err != nil {
+		if err := r.checkCapacity(tx, flight_id); err != nil {
 			tx.Rollback()
-			return err
+			return conflicts, err
+		}
+
+		if err := r.flightCeilings(tx, flight_id); err != nil {
+			tx.Rollback()
+			return conflicts, err
+		}
+
+		restrictions, err := r.flightRestrictions(tx, flight_id)
+		if err != nil {
+			tx.Rollback()
+			return conflicts, err
+		}
+
+		if blocking := ds.BlockingRestrictions(restrictions); len(blocking) > 0 {
+			tx.Rollback()
+			return conflicts, &ds.RestrictionError{Hits: blocking}
 		}
-		return tx.Commit().Error
+
+		new_status = ds.Completed
+		updates["date_finished"] = time.Now()
+		updates["rejection_code"] = ""
+		updates["rejection_reason"] = ""
+		reason = decision.Comment
 	}
 
-	if err := tx.Exec(`UPDATE public.flights SET status = ?, moderator_refer = ?, date_processed = ? WHERE id = ?`, new_status, uuid, time.Now(), flight_id).Error; err != nil {
+	if err := r.transitionFlight(tx, flight_id, new_status, uuid, moderatorRole, reason, updates); err != nil {
 		tx.Rollback()
-		return err
+		return conflicts, err
 	}
 
-	return tx.Commit().Error
-
+	return conflicts, tx.Commit().Error
 }
 
-func (r *Repository) UserConfirmFlight(uuid uuid.UUID, flight_id int) error {
-	tx := r.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+// UserConfirmFlight отправляет заявку на модерацию и в той же транзакции запрашивает у hours разрешённые часы.
+// Черновик, собранный по одному региону, высоту не указывает, и над регионами с потолком его не примут.
+func (r *Repository) UserConfirmFlight(uuid uuid.UUID, userRole role.Role, flight_id int, hours AllowedHoursProvider) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := r.transitionFlight(tx, flight_id, ds.Formed, uuid, userRole, "", nil); err != nil {
+			return err
 		}
-	}()
 
-	if err := tx.Exec(`UPDATE public.flights SET status = ?, user_refer = ? WHERE id = ?`, "Сформирован", uuid, flight_id).Error; err != nil {
-		tx.Rollback()
-		return err
-	}
+		if err := r.flightCeilings(tx, flight_id); err != nil {
+			return err
+		}
 
-	return tx.Commit().Error
+		flight := ds.Flight{}
+		if err := tx.First(&flight, "id = ?", flight_id).Error; err != nil {
+			return err
+		}
+
+		return hours.RequestAllowedHours(tx, flight)
+	})
 }
 
 func (r *Repository) FindRegion(region ds.Region) (ds.Region, error) {
@@ -282,14 +450,38 @@ func (r *Repository) FindRegion(region ds.Region) (ds.Region, error) {
 	err := r.db.Where(&region).First(&result).Error
 	if err != nil {
 		return ds.Region{}, err
-	} else {
-		return result, nil
 	}
+
+	regions := []ds.Region{result}
+	if err := r.fillDistrictNames(regions); err != nil {
+		return ds.Region{}, err
+	}
+
+	return regions[0], nil
 }
 
-func (r *Repository) SetAllowedHours(flight_id int, allowed_hours string) error {
-	log.Println(flight_id, allowed_hours)
-	return r.db.Model(&ds.Flight{}).Where("id = ?", flight_id).Update("allowed_hours", allowed_hours).Error
+// SetAllowedHours принимает разрешённые часы только для заявок, которые ждут модерации
+func (r *Repository) SetAllowedHours(flight_id int, allowed_hours []ds.TimeInterval) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		flight := ds.Flight{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&flight, "id = ?", flight_id).Error
+		if err != nil {
+			return err
+		}
+
+		if flight.Status != ds.Formed.String() {
+			return ds.ErrFlightNotFormed
+		}
+
+		if err := ds.ValidateAllowedHours(allowed_hours, flight.TakeoffDate, flight.ArrivalDate); err != nil {
+			return err
+		}
+
+		return tx.Model(&ds.Flight{}).Where("id = ?", flight_id).Updates(map[string]interface{}{
+			"allowed_hours": datatypes.NewJSONSlice(allowed_hours),
+			"version":       nextVersion,
+		}).Error
+	})
 }
 
 func (r *Repository) FindFlight(flight *ds.Flight) (ds.Flight, error) {
@@ -305,70 +497,316 @@ func (r *Repository) FindFlight(flight *ds.Flight) (ds.Flight, error) {
 	result.User = user
 
 	var moderator ds.User
-	r.db.Where("uuid = ?", result.ModeratorRefer).Find(&user)
+	r.db.Where("uuid = ?", result.ModeratorRefer).Find(&moderator)
 
 	result.Moderator = moderator
 
 	return result, nil
 }
 
+// EditRegion обновляет регион; region.Version - версия, которую видел клиент (0 - не проверять)
 func (r *Repository) EditRegion(region *ds.Region) error {
-	return r.db.Model(&ds.Region{}).Where("name = ?", region.Name).Updates(region).Error
+	if err := applyBoundary(region); err != nil {
+		return err
+	}
+
+	if err := r.resolveDistrict(region); err != nil {
+		return err
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkRegionVersion(tx, region.Name, region.Version); err != nil {
+			return err
+		}
+
+		// пустые поля не меняются, поэтому часы работы проверяются вместе с сохранённой половиной
+		current := ds.Region{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("opens_at", "closes_at").First(&current, "name = ?", region.Name).Error
+		if err != nil {
+			return err
+		}
+
+		opens_at, closes_at := region.OpensAt, region.ClosesAt
+		if opens_at == "" {
+			opens_at = current.OpensAt
+		}
+		if closes_at == "" {
+			closes_at = current.ClosesAt
+		}
+
+		if err := ds.ValidateOperatingHours(opens_at, closes_at); err != nil {
+			return err
+		}
+
+		err = tx.Model(&ds.Region{}).Where("name = ?", region.Name).Omit("version").Updates(region).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&ds.Region{}).Where("name = ?", region.Name).Update("version", nextVersion).Error
+	})
 }
 
-// TODO: check user
-func (r *Repository) EditFlight(flight *ds.Flight) error {
-	return r.db.Model(&ds.Flight{}).Where("id = ?", flight.ID).Updates(flight).Error
+// EditFlight правит черновик; при переносе полёта сдвигает и время участков, после чего они должны по-прежнему покрывать новое окно.
+// Диапазон высот меняется только целиком: 0..0 оставляет прежний.
+func (r *Repository) EditFlight(flight *ds.Flight, actor uuid.UUID, actorRole role.Role) error {
+	altitude_changed := flight.MinAltitude != 0 || flight.MaxAltitude != 0
+	if altitude_changed {
+		if err := ds.ValidateAltitudeBand(flight.MinAltitude, flight.MaxAltitude); err != nil {
+			return err
+		}
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		current, err := lockDraft(tx, int(flight.ID), flight.Version, actor, actorRole)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&ds.Flight{}).Where("id = ?", flight.ID).Omit("version", "min_altitude", "max_altitude").Updates(flight).Error; err != nil {
+			return err
+		}
+
+		if err := bumpFlightVersion(tx, int(flight.ID)); err != nil {
+			return err
+		}
+
+		if altitude_changed {
+			// нижняя граница может стать нулевой, которую Updates со структурой пропустил бы
+			err := tx.Model(&ds.Flight{}).Where("id = ?", flight.ID).Updates(map[string]interface{}{
+				"min_altitude": flight.MinAltitude,
+				"max_altitude": flight.MaxAltitude,
+			}).Error
+			if err != nil {
+				return err
+			}
+
+			legs, err := r.flightLegs(tx, int(flight.ID))
+			if err != nil {
+				return err
+			}
+
+			if err := r.checkCeilings(tx, legs, flight.MaxAltitude); err != nil {
+				return err
+			}
+		}
+
+		takeoff_date := current.TakeoffDate
+		if !flight.TakeoffDate.IsZero() {
+			takeoff_date = flight.TakeoffDate
+		}
+		arrival_date := current.ArrivalDate
+		if !flight.ArrivalDate.IsZero() {
+			arrival_date = flight.ArrivalDate
+		}
+
+		if takeoff_date.Equal(current.TakeoffDate) && arrival_date.Equal(current.ArrivalDate) {
+			return nil
+		}
+
+		legs, err := r.flightLegs(tx, int(flight.ID))
+		if err != nil {
+			return err
+		}
+
+		// при переносе взлёта участки едут вместе с ним, изменённое прибытие должно совпасть с концом последнего участка
+		shifted := !current.TakeoffDate.IsZero() && !takeoff_date.Equal(current.TakeoffDate)
+		if shifted {
+			legs = ds.ShiftLegs(legs, takeoff_date.Sub(current.TakeoffDate))
+		}
+
+		if err := ds.ValidateLegs(legs, takeoff_date, arrival_date); err != nil {
+			return err
+		}
+
+		if !shifted {
+			return nil
+		}
+
+		for _, leg := range legs {
+			err := tx.Model(&ds.FlightToRegion{}).Where("id = ?", leg.ID).Updates(map[string]interface{}{
+				"entry_date": leg.EntryDate,
+				"exit_date":  leg.ExitDate,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 }
 
 func (r *Repository) SetRegionImage(id int, image string) error {
-	return r.db.Model(&ds.Region{}).Where("id = ?", id).Update("image_name", image).Error
-}
+	return r.db.Model(&ds.Region{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"image_name": image,
+		"version":    nextVersion,
+	}).Error
+}
+
+// Book создаёт заявку или, если задано RRule, серию заявок; участки берутся из маршрута route, если он есть.
+// Возвращает пересечения с другими заявками и задетые ограничения полётов. Заявка не создаётся, если
+// пересекается с одобренной в регионе с запретом пересечений, задевает блокирующее ограничение или выше потолка региона.
+// Для каждой заявки, созданной сразу сформированной, в той же транзакции запрашиваются разрешённые часы у hours.
+func (r *Repository) Book(requestBody ds.BookRequestBody, route *ds.FlightRoute, userUUID uuid.UUID, userRole role.Role, hours AllowedHoursProvider) ([]ds.FlightConflict, []ds.RestrictionHit, error) {
+	status := ds.Draft
+	if requestBody.Status != "" {
+		var err error
+		status, err = ds.ParseFlightStatus(requestBody.Status)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 
-func (r *Repository) Book(requestBody ds.BookRequestBody, userUUID uuid.UUID) error {
-	var region_ids []int
-	for _, regionName := range requestBody.Regions {
-		region_id, err := r.GetRegionID(regionName)
+	// новая заявка может быть только черновиком или сразу сформированной
+	if status != ds.Draft && status != ds.Formed {
+		return nil, nil, &ds.TransitionError{From: ds.Draft, To: status, Role: userRole}
+	}
+
+	var legs []ds.FlightToRegion
+	if route != nil {
+		legs = ds.RouteLegs(*route)
+	} else {
+		var err error
+		legs, err = r.resolveLegs(requestBody.Legs, requestBody.Regions)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
-		region_ids = append(region_ids, region_id)
 	}
 
 	takeoff_date, err := time.Parse(time.RFC3339, requestBody.TakeoffDate)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	arrival_date, err := time.Parse(time.RFC3339, requestBody.ArrivalDate)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	flight := ds.Flight{}
-	flight.TakeoffDate = takeoff_date
-	flight.ArrivalDate = arrival_date
-	flight.UserRefer = &userUUID
-	flight.DateCreated = time.Now()
-	flight.Status = requestBody.Status
+	if err := ds.ValidateLegs(legs, takeoff_date, arrival_date); err != nil {
+		return nil, nil, err
+	}
 
-	err = r.db.Omit("moderator_refer", "date_processed", "date_finished").Create(&flight).Error
-	if err != nil {
-		return err
+	if requestBody.MinAltitude != 0 || requestBody.MaxAltitude != 0 {
+		if err := ds.ValidateAltitudeBand(requestBody.MinAltitude, requestBody.MaxAltitude); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	for _, region_id := range region_ids {
-		flight_to_region := ds.FlightToRegion{}
-		flight_to_region.FlightRefer = int(flight.ID)
-		flight_to_region.RegionRefer = int(region_id)
-		err = r.CreateFlightToRegion(flight_to_region)
+	takeoffs := []time.Time{takeoff_date}
+	if requestBody.RRule != "" {
+		// у каждого полёта серии свой черновик не заведёшь, поэтому серия сразу уходит на модерацию
+		if requestBody.Status == "" {
+			status = ds.Formed
+		}
+		if status == ds.Draft {
+			return nil, nil, ds.ErrDraftSeries
+		}
 
+		takeoffs, err = seriesTakeoffs(requestBody, takeoff_date)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 	}
+	duration := arrival_date.Sub(takeoff_date)
 
-	return nil
+	// по шаблону проверяются ограничения полётов для каждого вылета серии
+	template := ds.Flight{MinAltitude: requestBody.MinAltitude, MaxAltitude: requestBody.MaxAltitude}
+	if route != nil {
+		template.Route, template.CorridorWidth = route.Path, route.CorridorWidth
+	}
+
+	conflicts := []ds.FlightConflict{}
+	restrictions := []ds.RestrictionHit{}
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if err := r.checkCeilings(tx, legs, requestBody.MaxAltitude); err != nil {
+			return err
+		}
+
+		if err := lockBlockingRegions(tx, legs); err != nil {
+			return err
+		}
+
+		for _, takeoff := range takeoffs {
+			shifted := ds.ShiftLegs(legs, takeoff.Sub(takeoff_date))
+
+			found, err := r.findConflicts(tx, 0, shifted, takeoff, takeoff.Add(duration))
+			if err != nil {
+				return err
+			}
+			conflicts = append(conflicts, found...)
+
+			template.TakeoffDate, template.ArrivalDate = takeoff, takeoff.Add(duration)
+			hits, err := r.findRestrictions(tx, template, shifted)
+			if err != nil {
+				return err
+			}
+			restrictions = append(restrictions, hits...)
+		}
+
+		if blocking := ds.BlockingConflicts(conflicts); len(blocking) > 0 {
+			return &ds.ConflictError{Conflicts: blocking}
+		}
+
+		if blocking := ds.BlockingRestrictions(restrictions); len(blocking) > 0 {
+			return &ds.RestrictionError{Hits: blocking}
+		}
 
+		var series_id *uint
+		if requestBody.RRule != "" {
+			series := ds.FlightSeries{
+				UserRefer:   &userUUID,
+				RRule:       requestBody.RRule,
+				ExDates:     strings.Join(requestBody.ExDates, ","),
+				DateCreated: time.Now(),
+			}
+			if err := tx.Omit("User").Create(&series).Error; err != nil {
+				return err
+			}
+			series_id = &series.ID
+		}
+
+		for _, takeoff := range takeoffs {
+			flight := ds.Flight{}
+			flight.TakeoffDate = takeoff
+			flight.ArrivalDate = takeoff.Add(duration)
+			flight.UserRefer = &userUUID
+			flight.DateCreated = time.Now()
+			flight.Status = status.String()
+			flight.SeriesRefer = series_id
+			flight.MinAltitude = requestBody.MinAltitude
+			flight.MaxAltitude = requestBody.MaxAltitude
+			if route != nil {
+				flight.Route = route.Path
+				flight.CorridorWidth = route.CorridorWidth
+			}
+
+			err := tx.Omit("moderator_refer", "date_processed", "date_finished").Create(&flight).Error
+			if err != nil {
+				return draftError(err)
+			}
+
+			err = createLegs(tx, int(flight.ID), ds.ShiftLegs(legs, takeoff.Sub(takeoff_date)))
+			if err != nil {
+				return err
+			}
+
+			err = r.writeStatusEvent(tx, int(flight.ID), "", status, userUUID, userRole, "")
+			if err != nil {
+				return err
+			}
+
+			if status == ds.Formed {
+				if err := hours.RequestAllowedHours(tx, flight); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return conflicts, restrictions, err
 }
 
 func (r *Repository) GetFlightStatus(id int) (string, error) {
@@ -384,102 +822,139 @@ func (r *Repository) GetFlightStatus(id int) (string, error) {
 func (r *Repository) GetFlightRegions(id int) ([]ds.Region, error) {
 	flight_to_regions := []ds.FlightToRegion{}
 
-	err := r.db.Model(&ds.FlightToRegion{}).Where("flight_refer = ?", id).Find(&flight_to_regions).Error
+	err := r.db.Model(&ds.FlightToRegion{}).Where("flight_refer = ?", id).Order("sequence, id").Find(&flight_to_regions).Error
 	if err != nil {
 		return []ds.Region{}, err
 	}
 
+	// маршрут может возвращаться в уже пройденный регион, а в списке он нужен один раз
+	seen := map[int]bool{}
 	var regions []ds.Region
 	for _, flight_to_region := range flight_to_regions {
+		if seen[flight_to_region.RegionRefer] {
+			continue
+		}
+		seen[flight_to_region.RegionRefer] = true
+
 		region, err := r.GetRegionByID(flight_to_region.RegionRefer)
 		if err != nil {
 			return []ds.Region{}, err
 		}
-		for _, ele := range regions {
-			if ele == *region {
-				continue
-			}
-		}
 		regions = append(regions, *region)
 	}
 
-	return regions, nil
+	if err := r.fillDistrictNames(regions); err != nil {
+		return []ds.Region{}, err
+	}
 
+	return regions, nil
 }
 
-func (r *Repository) SetFlightRegions(flightID int, regions []string) error {
-	var region_ids []int
-	for _, region := range regions {
-		region_id, err := r.GetRegionID(region)
+// SetFlightRegions заменяет маршрут черновика и возвращает пересечения с другими заявками по новому маршруту.
+// version - версия заявки из If-Match, без неё маршрут не меняется.
+func (r *Repository) SetFlightRegions(flightID int, regions []string, requestLegs []ds.FlightLegRequest, version int, actor uuid.UUID, actorRole role.Role) ([]ds.FlightConflict, error) {
+	legs, err := r.resolveLegs(requestLegs, regions)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := []ds.FlightConflict{}
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		flight, err := lockDraft(tx, flightID, version, actor, actorRole)
 		if err != nil {
 			return err
 		}
 
-		for _, ele := range region_ids {
-			if ele == region_id {
-				continue
-			}
+		if err := ds.ValidateLegs(legs, flight.TakeoffDate, flight.ArrivalDate); err != nil {
+			return err
 		}
 
-		region_ids = append(region_ids, region_id)
-	}
+		if err := lockBlockingRegions(tx, legs); err != nil {
+			return err
+		}
 
-	var existing_links []ds.FlightToRegion
-	err := r.db.Model(&ds.FlightToRegion{}).Where("flight_refer = ?", flightID).Find(&existing_links).Error
-	if err != nil {
-		return err
-	}
+		conflicts, err = r.findConflicts(tx, flightID, legs, flight.TakeoffDate, flight.ArrivalDate)
+		if err != nil {
+			return err
+		}
 
-	for _, link := range existing_links {
-		regionFound := false
-		regionIndex := -1
-		for index, ele := range region_ids {
-			if ele == link.RegionRefer {
-				regionFound = true
-				regionIndex = index
-				break
-			}
+		if blocking := ds.BlockingConflicts(conflicts); len(blocking) > 0 {
+			return &ds.ConflictError{Conflicts: blocking}
 		}
 
-		if regionFound {
-			region_ids = append(region_ids[:regionIndex], region_ids[regionIndex+1:]...)
-		} else {
-			err := r.db.Model(&ds.FlightToRegion{}).Delete(&link).Error
-			if err != nil {
-				return err
-			}
+		if err := tx.Where("flight_refer = ?", flightID).Delete(&ds.FlightToRegion{}).Error; err != nil {
+			return err
 		}
-	}
 
-	for _, region_id := range region_ids {
-		newLink := ds.FlightToRegion{
-			FlightRefer: flightID,
-			RegionRefer: region_id,
+		if err := createLegs(tx, flightID, legs); err != nil {
+			return err
 		}
 
-		err := r.db.Model(&ds.FlightToRegion{}).Create(&newLink).Error
-		if err != nil {
-			return nil
+		if err := dropRoute(tx, flightID); err != nil {
+			return err
 		}
-	}
 
-	return nil
+		return bumpFlightVersion(tx, flightID)
+	})
+
+	return conflicts, err
 }
 
 func (r *Repository) SetFlightModerator(flightID int, moderatorUUID uuid.UUID) error {
 	return r.db.Model(&ds.Flight{}).Where("id = ?", flightID).Update("moderator_refer", moderatorUUID).Error
 }
 
-func (r *Repository) ChangeFlightStatusUser(id int, status string, userUUID uuid.UUID) error {
-	return r.db.Model(&ds.Flight{}).Where("id = ?", id).Where("user_refer = ?", userUUID).Update("status", status).Error
+func (r *Repository) ChangeFlightStatusUser(id int, status ds.FlightStatus, userUUID uuid.UUID, reason string) error {
+	return r.ChangeFlightStatus(id, status, userUUID, role.User, reason)
+}
+
+// ChangeFlightStatus - общий путь смены статуса, допускает только удаление и отмену
+func (r *Repository) ChangeFlightStatus(id int, status ds.FlightStatus, actor uuid.UUID, actorRole role.Role, reason string) error {
+	if err := ds.CheckGenericFlightTransition(status); err != nil {
+		return err
+	}
+
+	return r.changeFlightStatus(id, status, actor, actorRole, reason, nil)
 }
 
-func (r *Repository) ChangeFlightStatus(id int, status string) error {
-	return r.db.Model(&ds.Flight{}).Where("id = ?", id).Update("status", status).Error
+// CancelFlight отменяет одобренную заявку до взлёта, освобождая занятые ею места в регионах
+func (r *Repository) CancelFlight(id int, actor uuid.UUID, actorRole role.Role, reason string) error {
+	return r.changeFlightStatus(id, ds.Cancelled, actor, actorRole, reason, nil)
 }
 
+// DeleteFlightToRegion убирает участки над регионом и перенумеровывает оставшиеся.
+// Если без них участки больше не покрывают окно полёта, ничего не удаляется.
 func (r *Repository) DeleteFlightToRegion(flight_id int, region_id int) error {
-	return r.db.Where("flight_refer = ?", flight_id).Where("region_refer = ?", region_id).Delete(&ds.FlightToRegion{}).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		flight := ds.Flight{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&flight, "id = ?", flight_id).Error; err != nil {
+			return err
+		}
+
+		err := tx.Where("flight_refer = ?", flight_id).Where("region_refer = ?", region_id).Delete(&ds.FlightToRegion{}).Error
+		if err != nil {
+			return err
+		}
+
+		legs, err := r.flightLegs(tx, flight_id)
+		if err != nil {
+			return err
+		}
+
+		if err := renumberLegs(tx, legs); err != nil {
+			return err
+		}
+
+		if err := ds.ValidateLegs(legs, flight.TakeoffDate, flight.ArrivalDate); err != nil {
+			return err
+		}
+
+		if err := dropRoute(tx, flight_id); err != nil {
+			return err
+		}
+
+		return bumpFlightVersion(tx, flight_id)
+	})
 }
 
 func (r *Repository) Register(user *ds.User) error {
@@ -0,0 +1,283 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"drones/internal/app/ds"
+	"drones/internal/app/geo"
+)
+
+// с какими заявками сверяется новое ограничение
+var approvedStatuses = []string{ds.Completed.String(), ds.InFlight.String()}
+
+// findRestrictions ищет неснятые ограничения в регионах участков legs, пересекающиеся с ними по времени и высоте.
+// Заявка без диапазона высот задевает ограничение на любой высоте.
+// Заявка с маршрутом не задевает ограничение с областью, если коридор проходит мимо области.
+func (r *Repository) findRestrictions(tx *gorm.DB, flight ds.Flight, legs []ds.FlightToRegion) ([]ds.RestrictionHit, error) {
+	hits := []ds.RestrictionHit{}
+
+	if flight.TakeoffDate.IsZero() || flight.ArrivalDate.IsZero() {
+		return hits, nil
+	}
+
+	route := parseStoredRoute(flight.Route)
+
+	type hitKey struct {
+		restrictionID uint
+		regionID      uint
+	}
+	seen := map[hitKey]bool{}
+	areas := map[uint]geo.MultiPolygon{}
+
+	for _, leg := range legs {
+		from, to := ds.LegWindow(leg, flight.TakeoffDate, flight.ArrivalDate)
+
+		found := []ds.RestrictionHit{}
+		err := tx.Table("restriction_to_regions").
+			Select("restrictions.id AS restriction_id, restrictions.reason, restrictions.starts_at, restrictions.ends_at, restrictions.min_altitude, restrictions.max_altitude, restrictions.blocking, regions.id AS region_id, regions.name AS region_name").
+			Joins("JOIN restrictions ON restrictions.id = restriction_to_regions.restriction_refer").
+			Joins("JOIN regions ON regions.id = restriction_to_regions.region_refer").
+			Where("restriction_to_regions.region_refer = ?", leg.RegionRefer).
+			Where("restrictions.date_lifted IS NULL").
+			Where("restrictions.starts_at < ? AND restrictions.ends_at > ?", to, from).
+			Where("? = 0 OR (restrictions.min_altitude < ? AND restrictions.max_altitude > ?)", flight.MaxAltitude, flight.MaxAltitude, flight.MinAltitude).
+			Order("restrictions.starts_at, restrictions.id").
+			Scan(&found).Error
+		if err != nil {
+			return nil, err
+		}
+
+		for _, hit := range found {
+			key := hitKey{restrictionID: hit.RestrictionID, regionID: hit.RegionID}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			area, err := restrictionArea(tx, areas, hit.RestrictionID)
+			if err != nil {
+				return nil, err
+			}
+			if routeMisses(area, route, flight.CorridorWidth) {
+				continue
+			}
+
+			hits = append(hits, hit)
+		}
+	}
+
+	return hits, nil
+}
+
+// restrictionArea возвращает область ограничения; nil - ограничение задано только регионами
+func restrictionArea(tx *gorm.DB, cache map[uint]geo.MultiPolygon, restriction_id uint) (geo.MultiPolygon, error) {
+	if area, ok := cache[restriction_id]; ok {
+		return area, nil
+	}
+
+	restriction := ds.Restriction{}
+	if err := tx.Select("id", "boundary").First(&restriction, "id = ?", restriction_id).Error; err != nil {
+		return nil, err
+	}
+
+	var area geo.MultiPolygon
+	if len(restriction.Boundary) > 0 {
+		var err error
+		area, err = geo.ParseBoundary(restriction.Boundary)
+		if err != nil {
+			return nil, err
+		}
+	}
+	cache[restriction_id] = area
+
+	return area, nil
+}
+
+// routeMisses - коридор маршрута проходит мимо области, хотя заявка и летит через тот же регион
+func routeMisses(area geo.MultiPolygon, route geo.LineString, corridor_width float64) bool {
+	if area == nil || route == nil {
+		return false
+	}
+
+	_, _, ok := area.RouteEntry(route, corridor_width/2)
+	return !ok
+}
+
+// parseStoredRoute разбирает сохранённый маршрут заявки; nil - маршрута нет
+func parseStoredRoute(path datatypes.JSON) geo.LineString {
+	if len(path) == 0 {
+		return nil
+	}
+
+	// маршрут проверяется при бронировании; если он всё же не разбирается, заявку проверяем по регионам
+	route, err := geo.ParseRoute(path)
+	if err != nil {
+		return nil
+	}
+
+	return route
+}
+
+func (r *Repository) flightRestrictions(tx *gorm.DB, flight_id int) ([]ds.RestrictionHit, error) {
+	flight := ds.Flight{}
+	if err := tx.First(&flight, "id = ?", flight_id).Error; err != nil {
+		return nil, err
+	}
+
+	legs, err := r.flightLegs(tx, flight_id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.findRestrictions(tx, flight, legs)
+}
+
+func (r *Repository) GetFlightRestrictions(flight_id int) ([]ds.RestrictionHit, error) {
+	return r.flightRestrictions(r.db, flight_id)
+}
+
+// CreateRestriction сохраняет ограничение над регионами region_names и area_region_ids (регионами, которые задевает его область)
+// и возвращает уже одобренные заявки, которые оно задевает. Заявки без диапазона высот задеваются на любой высоте.
+func (r *Repository) CreateRestriction(restriction *ds.Restriction, region_names []string, area_region_ids []int) ([]ds.FlightConflict, error) {
+	region_ids := []int{}
+	seen := map[int]bool{}
+	for _, region_id := range area_region_ids {
+		if !seen[region_id] {
+			seen[region_id] = true
+			region_ids = append(region_ids, region_id)
+		}
+	}
+	for _, name := range region_names {
+		region_id, err := r.GetRegionID(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[region_id] {
+			seen[region_id] = true
+			region_ids = append(region_ids, region_id)
+		}
+	}
+
+	if len(region_ids) == 0 {
+		return nil, fmt.Errorf("%w: restriction doesn't cover any region", ds.ErrInvalidRestriction)
+	}
+
+	var area geo.MultiPolygon
+	if len(restriction.Boundary) > 0 {
+		var err error
+		area, err = geo.ParseBoundary(restriction.Boundary)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	conflicts := []ds.FlightConflict{}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Creator").Create(restriction).Error; err != nil {
+			return err
+		}
+
+		for _, region_id := range region_ids {
+			link := ds.RestrictionToRegion{RestrictionRefer: int(restriction.ID), RegionRefer: region_id}
+			if err := tx.Omit("Restriction", "Region").Create(&link).Error; err != nil {
+				return err
+			}
+		}
+
+		type affectedLeg struct {
+			ds.FlightConflict
+			Route         datatypes.JSON
+			CorridorWidth float64
+		}
+
+		found := []affectedLeg{}
+		err := tx.Table("flight_to_regions").
+			Select("flights.id AS flight_id, flights.status, "+legEntrySQL+" AS takeoff_date, "+legExitSQL+" AS arrival_date, regions.id AS region_id, regions.name AS region_name, flights.route, flights.corridor_width").
+			Joins("JOIN flights ON flights.id = flight_to_regions.flight_refer").
+			Joins("JOIN regions ON regions.id = flight_to_regions.region_refer").
+			Where("flight_to_regions.region_refer IN ?", region_ids).
+			Where("flights.status IN ?", approvedStatuses).
+			Where(legEntrySQL+" < ? AND "+legExitSQL+" > ?", restriction.EndsAt, restriction.StartsAt).
+			Where("flights.max_altitude = 0 OR (flights.min_altitude < ? AND flights.max_altitude > ?)", restriction.MaxAltitude, restriction.MinAltitude).
+			Order("takeoff_date, flights.id").
+			Scan(&found).Error
+		if err != nil {
+			return err
+		}
+
+		for _, leg := range found {
+			if routeMisses(area, parseStoredRoute(leg.Route), leg.CorridorWidth) {
+				continue
+			}
+
+			conflict := leg.FlightConflict
+			conflict.Blocking = restriction.Blocking
+			conflicts = append(conflicts, conflict)
+		}
+
+		restriction.Regions, err = restrictionRegionNames(tx, int(restriction.ID))
+		return err
+	})
+
+	return conflicts, err
+}
+
+func restrictionRegionNames(tx *gorm.DB, restriction_id int) ([]string, error) {
+	names := []string{}
+
+	err := tx.Table("restriction_to_regions").
+		Joins("JOIN regions ON regions.id = restriction_to_regions.region_refer").
+		Where("restriction_to_regions.restriction_refer = ?", restriction_id).
+		Order("regions.name").
+		Pluck("regions.name", &names).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// GetRestrictions возвращает ограничения; activeOnly - только неснятые и ещё не закончившиеся
+func (r *Repository) GetRestrictions(activeOnly bool) ([]ds.Restriction, error) {
+	restrictions := []ds.Restriction{}
+
+	query := r.db.Order("starts_at, id")
+	if activeOnly {
+		query = query.Where("date_lifted IS NULL").Where("ends_at > ?", time.Now())
+	}
+
+	if err := query.Find(&restrictions).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range restrictions {
+		names, err := restrictionRegionNames(r.db, int(restrictions[i].ID))
+		if err != nil {
+			return nil, err
+		}
+		restrictions[i].Regions = names
+	}
+
+	return restrictions, nil
+}
+
+// LiftRestriction снимает ограничение досрочно
+func (r *Repository) LiftRestriction(restriction_id int) error {
+	result := r.db.Model(&ds.Restriction{}).
+		Where("id = ?", restriction_id).Where("date_lifted IS NULL").
+		Update("date_lifted", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
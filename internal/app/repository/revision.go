@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"drones/internal/app/ds"
+	"drones/internal/app/role"
+)
+
+// ResubmitFlight создаёт новую редакцию отклонённой заявки и сразу отправляет её на модерацию.
+// Нулевые даты и пустой маршрут берутся из отклонённой заявки, сама она остаётся отклонённой.
+// У отклонённой заявки может быть только одна новая редакция.
+// Редакция проходит те же проверки, что и новая заявка, и так же, как при подтверждении пользователем,
+// в той же транзакции запрашивает у hours разрешённые часы.
+func (r *Repository) ResubmitFlight(parent_id int, takeoff_date time.Time, arrival_date time.Time, regions []string, requestLegs []ds.FlightLegRequest, userUUID uuid.UUID, userRole role.Role, hours AllowedHoursProvider) (ds.Flight, []ds.FlightConflict, error) {
+	var legs []ds.FlightToRegion
+	if len(regions) > 0 || len(requestLegs) > 0 {
+		var err error
+		legs, err = r.resolveLegs(requestLegs, regions)
+		if err != nil {
+			return ds.Flight{}, nil, err
+		}
+	}
+
+	revision := ds.Flight{}
+	conflicts := []ds.FlightConflict{}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		parent := ds.Flight{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&parent, "id = ?", parent_id).Error
+		if err != nil {
+			return err
+		}
+
+		if userRole == role.User && (parent.UserRefer == nil || *parent.UserRefer != userUUID) {
+			return ds.ErrFlightNotOwned
+		}
+
+		if parent.Status != ds.Rejected.String() {
+			from, err := ds.ParseFlightStatus(parent.Status)
+			if err != nil {
+				return err
+			}
+			return &ds.TransitionError{From: from, To: ds.Formed, Role: userRole}
+		}
+
+		var revisions int64
+		err = tx.Model(&ds.Flight{}).Where("parent_refer = ?", parent_id).Count(&revisions).Error
+		if err != nil {
+			return err
+		}
+		if revisions > 0 {
+			return ds.ErrAlreadyResubmitted
+		}
+
+		if takeoff_date.IsZero() {
+			takeoff_date = parent.TakeoffDate
+		}
+		if arrival_date.IsZero() {
+			arrival_date = takeoff_date.Add(parent.ArrivalDate.Sub(parent.TakeoffDate))
+		}
+
+		// маршрут остаётся, только если новая редакция летит по тем же регионам
+		var route_path []byte
+		var corridor_width float64
+		if legs == nil {
+			route_path, corridor_width = parent.Route, parent.CorridorWidth
+
+			legs, err = r.flightLegs(tx, parent_id)
+			if err != nil {
+				return err
+			}
+			legs = ds.ShiftLegs(legs, takeoff_date.Sub(parent.TakeoffDate))
+		}
+
+		if err := ds.ValidateLegs(legs, takeoff_date, arrival_date); err != nil {
+			return err
+		}
+
+		if err := lockBlockingRegions(tx, legs); err != nil {
+			return err
+		}
+
+		conflicts, err = r.findConflicts(tx, 0, legs, takeoff_date, arrival_date)
+		if err != nil {
+			return err
+		}
+
+		if blocking := ds.BlockingConflicts(conflicts); len(blocking) > 0 {
+			return &ds.ConflictError{Conflicts: blocking}
+		}
+
+		parentID := parent.ID
+		revision = ds.Flight{
+			UserRefer:     parent.UserRefer,
+			Status:        ds.Formed.String(),
+			DateCreated:   time.Now(),
+			TakeoffDate:   takeoff_date,
+			ArrivalDate:   arrival_date,
+			SeriesRefer:   parent.SeriesRefer,
+			ParentRefer:   &parentID,
+			Route:         route_path,
+			CorridorWidth: corridor_width,
+			MinAltitude:   parent.MinAltitude,
+			MaxAltitude:   parent.MaxAltitude,
+		}
+
+		if err := r.checkCeilings(tx, legs, revision.MaxAltitude); err != nil {
+			return err
+		}
+
+		restrictions, err := r.findRestrictions(tx, revision, legs)
+		if err != nil {
+			return err
+		}
+
+		if blocking := ds.BlockingRestrictions(restrictions); len(blocking) > 0 {
+			return &ds.RestrictionError{Hits: blocking}
+		}
+
+		err = tx.Omit("moderator_refer", "date_processed", "date_finished").Create(&revision).Error
+		if err != nil {
+			return err
+		}
+
+		if err := createLegs(tx, int(revision.ID), legs); err != nil {
+			return err
+		}
+
+		err = r.writeStatusEvent(tx, int(revision.ID), "", ds.Formed, userUUID, userRole, fmt.Sprintf("повторная подача заявки %d", parent.ID))
+		if err != nil {
+			return err
+		}
+
+		return hours.RequestAllowedHours(tx, revision)
+	})
+
+	return revision, conflicts, err
+}
+
+// GetFlightRevisionDiff сравнивает заявку с отклонённой, из которой она получена. Для первой редакции возвращает nil.
+func (r *Repository) GetFlightRevisionDiff(flight ds.Flight) (*ds.FlightRevisionDiff, error) {
+	if flight.ParentRefer == nil {
+		return nil, nil
+	}
+
+	parent, err := r.GetFlightByID(int(*flight.ParentRefer))
+	if err != nil {
+		return nil, err
+	}
+
+	parentLegs, err := r.GetFlightLegs(int(parent.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	legs, err := r.GetFlightLegs(int(flight.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	diff := ds.DiffRevision(*parent, parentLegs, flight, legs)
+	return &diff, nil
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"drones/internal/app/ds"
+)
+
+// dropRoute забывает маршрут заявки, когда её регионы правят вручную: он им больше не соответствует
+func dropRoute(tx *gorm.DB, flight_id int) error {
+	return tx.Model(&ds.Flight{}).Where("id = ?", flight_id).Updates(map[string]interface{}{
+		"route":          nil,
+		"corridor_width": 0,
+	}).Error
+}
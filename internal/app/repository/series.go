@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"drones/internal/app/ds"
+	"drones/internal/app/recurrence"
+	"drones/internal/app/role"
+)
+
+func seriesTakeoffs(requestBody ds.BookRequestBody, start time.Time) ([]time.Time, error) {
+	rule, err := recurrence.Parse(requestBody.RRule)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ds.ErrInvalidRecurrence, err)
+	}
+
+	exdates := []time.Time{}
+	for _, exdate := range requestBody.ExDates {
+		parsed, err := time.Parse(time.RFC3339, exdate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ds.ErrInvalidRecurrence, err)
+		}
+		exdates = append(exdates, parsed)
+	}
+
+	takeoffs, err := rule.Occurrences(start, exdates)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ds.ErrInvalidRecurrence, err)
+	}
+
+	if len(takeoffs) == 0 {
+		return nil, fmt.Errorf("%w: rule produces no flights", ds.ErrInvalidRecurrence)
+	}
+
+	return takeoffs, nil
+}
+
+func (r *Repository) GetSeries(series_id int) (*ds.FlightSeries, error) {
+	series := &ds.FlightSeries{}
+
+	err := r.db.First(series, "id = ?", series_id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
+
+func (r *Repository) GetSeriesFlights(series_id int) ([]ds.Flight, error) {
+	flights := []ds.Flight{}
+
+	err := r.db.Where("series_refer = ?", series_id).Order("takeoff_date").Find(&flights).Error
+	if err != nil {
+		return nil, err
+	}
+
+	r.fillFlightUsers(flights)
+
+	return flights, nil
+}
+
+// FutureSeriesFlights - полёты серии, которые ещё не взлетели
+func (r *Repository) FutureSeriesFlights(series_id int) ([]ds.Flight, error) {
+	flights := []ds.Flight{}
+
+	err := r.db.Where("series_refer = ?", series_id).Where("takeoff_date > ?", time.Now()).Order("takeoff_date").Find(&flights).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return flights, nil
+}
+
+// CancelSeries снимает все будущие полёты серии, кроме перечисленных в Keep
+func (r *Repository) CancelSeries(actor uuid.UUID, actorRole role.Role, series_id int, requestBody ds.SeriesCancelRequestBody) ([]ds.FlightActionResult, error) {
+	flights, err := r.FutureSeriesFlights(series_id)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := map[uint]bool{}
+	for _, flight_id := range requestBody.Keep {
+		keep[flight_id] = true
+	}
+
+	results := []ds.FlightActionResult{}
+	for _, flight := range flights {
+		if keep[flight.ID] || flight.Status == ds.Deleted.String() || flight.Status == ds.Rejected.String() ||
+			flight.Status == ds.Cancelled.String() {
+			continue
+		}
+
+		// одобренные полёты не удаляются, а отменяются
+		to := ds.Deleted
+		if flight.Status == ds.Completed.String() {
+			to = ds.Cancelled
+		}
+
+		err := r.changeFlightStatus(int(flight.ID), to, actor, actorRole, requestBody.Reason, nil)
+		results = append(results, ds.NewFlightActionResult(flight, to, err))
+	}
+
+	return results, nil
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"drones/internal/app/ds"
+	"drones/internal/app/role"
+)
+
+// transitionFlight - единственное место, где меняется статус заявки.
+// Блокирует строку, проверяет переход по таблице ds, применяет updates вместе со статусом
+// и записывает событие в историю в той же транзакции.
+func (r *Repository) transitionFlight(tx *gorm.DB, flight_id int, to ds.FlightStatus, actor uuid.UUID, actorRole role.Role, reason string, updates map[string]interface{}) error {
+	flight := ds.Flight{}
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&flight, "id = ?", flight_id).Error
+	if err != nil {
+		return err
+	}
+
+	from, err := ds.ParseFlightStatus(flight.Status)
+	if err != nil {
+		return err
+	}
+
+	if actorRole == role.User && (flight.UserRefer == nil || *flight.UserRefer != actor) {
+		return ds.ErrFlightNotOwned
+	}
+
+	if err := ds.CheckFlightTransition(from, to, actorRole); err != nil {
+		return err
+	}
+
+	if to == ds.Cancelled {
+		if err := ds.CheckCancellation(flight, reason, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+	updates["status"] = to.String()
+	updates["version"] = nextVersion
+
+	err = tx.Model(&ds.Flight{}).Where("id = ?", flight_id).Updates(updates).Error
+	if err != nil {
+		return err
+	}
+
+	err = r.writeStatusEvent(tx, flight_id, flight.Status, to, actor, actorRole, reason)
+	if err != nil {
+		return err
+	}
+
+	// места в регионах отменённая заявка больше не занимает (см. capacityStatuses), об этом стоит знать одобрившему её модератору
+	if to == ds.Cancelled && flight.ModeratorRefer != nil && *flight.ModeratorRefer != actor {
+		return r.notify(tx, *flight.ModeratorRefer, flight_id, fmt.Sprintf("Заявка %d, которую вы одобрили, отменена %s: %s", flight_id, cancelledBy(actorRole), reason))
+	}
+
+	return nil
+}
+
+// lockDraft блокирует черновик перед правкой: версия обязательна, пользователь правит только свои заявки
+func lockDraft(tx *gorm.DB, flight_id int, version int, actor uuid.UUID, actorRole role.Role) (ds.Flight, error) {
+	if version == 0 {
+		return ds.Flight{}, ds.ErrVersionRequired
+	}
+
+	if err := checkFlightVersion(tx, flight_id, version); err != nil {
+		return ds.Flight{}, err
+	}
+
+	flight := ds.Flight{}
+	if err := tx.First(&flight, "id = ?", flight_id).Error; err != nil {
+		return ds.Flight{}, err
+	}
+
+	if actorRole == role.User && (flight.UserRefer == nil || *flight.UserRefer != actor) {
+		return ds.Flight{}, ds.ErrFlightNotOwned
+	}
+
+	if flight.Status != ds.Draft.String() {
+		return ds.Flight{}, ds.ErrFlightNotDraft
+	}
+
+	return flight, nil
+}
+
+// cancelledBy - кем отменена заявка, для текста уведомления
+func cancelledBy(actorRole role.Role) string {
+	switch actorRole {
+	case role.Moderator:
+		return "модератором"
+	case role.Admin:
+		return "администратором"
+	case role.System:
+		return "системой"
+	}
+
+	return "пользователем"
+}
+
+// changeFlightStatus оборачивает transitionFlight в отдельную транзакцию
+func (r *Repository) changeFlightStatus(flight_id int, to ds.FlightStatus, actor uuid.UUID, actorRole role.Role, reason string, updates map[string]interface{}) error {
+	tx := r.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := r.transitionFlight(tx, flight_id, to, actor, actorRole, reason, updates); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+func (r *Repository) writeStatusEvent(tx *gorm.DB, flight_id int, from string, to ds.FlightStatus, actor uuid.UUID, actorRole role.Role, reason string) error {
+	event := ds.FlightStatusEvent{
+		FlightRefer: flight_id,
+		FromStatus:  from,
+		ToStatus:    to.String(),
+		ActorRole:   actorRole,
+		Reason:      reason,
+		DateCreated: time.Now(),
+	}
+
+	if actor != uuid.Nil {
+		event.ActorRefer = &actor
+	}
+
+	return tx.Omit("Flight").Create(&event).Error
+}
+
+func (r *Repository) GetFlightHistory(flight_id int) ([]ds.FlightStatusEvent, error) {
+	events := []ds.FlightStatusEvent{}
+
+	err := r.db.Where("flight_refer = ?", flight_id).Order("date_created, id").Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"drones/internal/app/ds"
+)
+
+// версия растёт при каждом изменении строки; клиент присылает прочитанную версию в If-Match
+var nextVersion = gorm.Expr("version + 1")
+
+// checkFlightVersion блокирует заявку до конца транзакции и сверяет её версию с ожидаемой. 0 - не проверять.
+func checkFlightVersion(tx *gorm.DB, flight_id int, expected int) error {
+	if expected == 0 {
+		return nil
+	}
+
+	flight := ds.Flight{}
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "version").First(&flight, "id = ?", flight_id).Error
+	if err != nil {
+		return err
+	}
+
+	if flight.Version != expected {
+		return ds.ErrVersionMismatch
+	}
+
+	return nil
+}
+
+func checkRegionVersion(tx *gorm.DB, name string, expected int) error {
+	if expected == 0 {
+		return nil
+	}
+
+	region := ds.Region{}
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "version").First(&region, "name = ?", name).Error
+	if err != nil {
+		return err
+	}
+
+	if region.Version != expected {
+		return ds.ErrVersionMismatch
+	}
+
+	return nil
+}
+
+// bumpFlightVersion поднимает версию заявки и отмечает время её изменения, по которому чистятся брошенные черновики
+func bumpFlightVersion(tx *gorm.DB, flight_id int) error {
+	return tx.Model(&ds.Flight{}).Where("id = ?", flight_id).Updates(map[string]interface{}{
+		"version":       nextVersion,
+		"date_modified": time.Now(),
+	}).Error
+}
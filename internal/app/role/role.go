@@ -7,4 +7,5 @@ const (
 	User
 	Moderator
 	Admin
+	System // фоновые задачи сервиса, в токенах не встречается
 )
@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"drones/internal/app/config"
+	"drones/internal/app/redis"
+)
+
+// имя группы, в которой экземпляры сервиса выбирают лидера
+const leaderName = "scheduler"
+
+type Job struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Scheduler периодически запускает задачи. Экземпляров сервиса может быть несколько,
+// поэтому задачи выполняет только тот, кто держит лидерство в Redis.
+type Scheduler struct {
+	redis    *redis.Client
+	cfg      config.SchedulerConfig
+	instance string
+	jobs     []Job
+}
+
+func New(redisClient *redis.Client, cfg config.SchedulerConfig, jobs ...Job) *Scheduler {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	// лидерство продлевается раз в Interval; при равных сроках оно истекает ровно к следующему тику и переходит к другому экземпляру
+	if cfg.LeaderTTL <= cfg.Interval {
+		cfg.LeaderTTL = 2 * cfg.Interval
+	}
+
+	return &Scheduler{
+		redis:    redisClient,
+		cfg:      cfg,
+		instance: uuid.New().String(),
+		jobs:     jobs,
+	}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.tick(ctx)
+
+		select {
+		case <-ctx.Done():
+			if err := s.redis.ReleaseLeadership(context.Background(), leaderName, s.instance); err != nil {
+				log.Println("scheduler: can't release leadership:", err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) tick(ctx context.Context) {
+	leader, err := s.redis.AcquireLeadership(ctx, leaderName, s.instance, s.cfg.LeaderTTL)
+	if err != nil {
+		log.Println("scheduler: can't acquire leadership:", err)
+		return
+	}
+
+	if !leader {
+		return
+	}
+
+	for _, job := range s.jobs {
+		if err := job.Run(ctx); err != nil {
+			log.Printf("scheduler: job %s failed: %v", job.Name, err)
+		}
+	}
+}
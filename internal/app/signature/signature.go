@@ -0,0 +1,37 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Подпись обратных вызовов от внешних сервисов: hex(HMAC-SHA256(secret, timestamp + "." + body)).
+// timestamp - unix-время в секундах из заголовка TimestampHeader.
+const (
+	TimestampHeader = "X-Timestamp"
+	SignatureHeader = "X-Signature"
+)
+
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func Verify(secret []byte, timestamp string, body []byte, signature string) bool {
+	expected, err := hex.DecodeString(Sign(secret, timestamp, body))
+	if err != nil {
+		return false
+	}
+
+	actual, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(expected, actual)
+}
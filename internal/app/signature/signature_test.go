@@ -0,0 +1,55 @@
+package signature
+
+import "testing"
+
+func TestSign(t *testing.T) {
+	// посчитано независимо: printf '1700000000.{}' | openssl dgst -sha256 -hmac secret
+	const want = "b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163"
+
+	if got := Sign([]byte("secret"), "1700000000", []byte("{}")); got != want {
+		t.Fatalf("Sign() = %q, want %q", got, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"PK":"1"}`)
+	valid := Sign(secret, "1700000000", body)
+
+	tests := []struct {
+		name      string
+		secret    []byte
+		timestamp string
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{name: "valid", secret: secret, timestamp: "1700000000", body: body, signature: valid, want: true},
+		{name: "upper case hex", secret: secret, timestamp: "1700000000", body: body, signature: upper(valid), want: true},
+		{name: "other secret", secret: []byte("other"), timestamp: "1700000000", body: body, signature: valid},
+		{name: "other timestamp", secret: secret, timestamp: "1700000001", body: body, signature: valid},
+		{name: "other body", secret: secret, timestamp: "1700000000", body: []byte(`{"PK":"2"}`), signature: valid},
+		{name: "not hex", secret: secret, timestamp: "1700000000", body: body, signature: "zz"},
+		{name: "truncated", secret: secret, timestamp: "1700000000", body: body, signature: valid[:62]},
+		{name: "empty", secret: secret, timestamp: "1700000000", body: body, signature: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.secret, tt.timestamp, tt.body, tt.signature); got != tt.want {
+				t.Fatalf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func upper(s string) string {
+	result := []byte(s)
+	for i, c := range result {
+		if c >= 'a' && c <= 'f' {
+			result[i] = c - 'a' + 'A'
+		}
+	}
+
+	return string(result)
+}
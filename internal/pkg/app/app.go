@@ -1,26 +1,30 @@
 package app
 
 import (
-	"bytes"
 	"context"
 	"crypto/sha1"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"drones/docs"
 	"drones/internal/app/config"
 	"drones/internal/app/ds"
 	"drones/internal/app/dsn"
+	"drones/internal/app/geo"
+	"drones/internal/app/outbox"
 	"drones/internal/app/redis"
 	"drones/internal/app/repository"
 	"drones/internal/app/role"
+	"drones/internal/app/scheduler"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
@@ -31,15 +35,22 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // @BasePath /
 
 type Application struct {
-	repo   *repository.Repository
-	r      *gin.Engine
-	config *config.Config
-	redis  *redis.Client
+	repo      *repository.Repository
+	r         *gin.Engine
+	config    *config.Config
+	redis     *redis.Client
+	scheduler *scheduler.Scheduler
+	outbox    *outbox.Dispatcher
+	hours     repository.AllowedHoursProvider
+	regions   *geo.RegionIndex
+
+	regionsVersion atomic.Int64 // версия границ из redis, по которой построен regions
 }
 
 type loginReq struct {
@@ -71,16 +82,53 @@ func New(ctx context.Context) (*Application, error) {
 		return nil, err
 	}
 
-	return &Application{
+	a := &Application{
 		config: cfg,
 		repo:   repo,
 		redis:  redisClient,
-	}, nil
+	}
+	a.scheduler = scheduler.New(redisClient, cfg.Scheduler, a.jobs()...)
+	a.outbox = outbox.New(repo, cfg.Outbox)
+
+	a.hours, err = newHoursProvider(repo, cfg.Hours)
+	if err != nil {
+		return nil, err
+	}
+
+	a.regions = geo.NewRegionIndex()
+	version, err := redisClient.GetRegionsVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.rebuildRegionIndex(); err != nil {
+		return nil, err
+	}
+	a.regionsVersion.Store(version)
+
+	return a, nil
+}
+
+func newHoursProvider(repo *repository.Repository, cfg config.AllowedHoursConfig) (repository.AllowedHoursProvider, error) {
+	switch cfg.Provider {
+	case "", "service":
+		return repository.ServiceHoursProvider{}, nil
+	case "local":
+		location, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		return repository.NewLocalHoursProvider(repo, location), nil
+	}
+
+	return nil, fmt.Errorf("unknown allowed hours provider %q", cfg.Provider)
 }
 
 func (a *Application) StartServer() {
 	log.Println("Server started")
 
+	go a.scheduler.Run(context.Background())
+	go a.outbox.Run(context.Background())
+
 	a.r = gin.Default()
 
 	// swagger
@@ -89,30 +137,60 @@ func (a *Application) StartServer() {
 
 	a.r.Use(a.WithAuthCheck(role.Moderator, role.Admin, role.User, role.Undefined)).GET("regions", a.get_regions)
 	a.r.GET("region/:region", a.get_region)
+	a.r.GET("regions/at", a.get_regions_at)
+	a.r.GET("regions/within", a.get_regions_within)
+	a.r.GET("districts", a.get_districts)
+	a.r.GET("district/:district_id", a.get_district)
 
 	// registration & etc
 	a.r.POST("/login", a.login)
 	a.r.POST("/register", a.register)
 	a.r.POST("/logout", a.logout)
 
+	// обратный вызов сервиса разрешённых часов: без токена пользователя, но с подписью
+	a.r.PUT("flight/set_allowed_hours", a.WithCallbackSignature(), a.set_allowed_hours)
+
 	a.r.Use(a.WithAuthCheck(role.Moderator, role.Admin, role.User)).GET("flight", a.get_flight)
-	a.r.PUT("flight/set_allowed_hours", a.set_allowed_hours)
 	a.r.POST("region/add_to_flight/:id", a.add_region_to_flight)
 	a.r.DELETE("flight_to_region/delete", a.delete_flight_to_region)
 	a.r.GET("flights", a.get_flights)
+	a.r.GET("flight/rejection_reasons", a.get_rejection_reasons)
+	a.r.GET("flight/:flight_id/history", a.get_flight_history)
+	a.r.POST("flight/:flight_id/clone", a.clone_flight)
+	a.r.PUT("flight/:flight_id/resubmit", a.resubmit_flight)
+	a.r.PUT("flight/:flight_id/cancel", a.cancel_flight)
+	a.r.PUT("flight/:flight_id/takeoff", a.report_takeoff)
+	a.r.PUT("flight/:flight_id/landing", a.report_landing)
+	a.r.GET("notifications", a.get_notifications)
+	a.r.GET("restrictions", a.get_restrictions)
+	a.r.PUT("notifications/:notification_id/read", a.read_notification)
 	a.r.PUT("flight/edit", a.edit_flight)
 	a.r.PUT("book", a.book)
 	a.r.PUT("flight/status_change", a.flight_status_change)
 	a.r.DELETE("flight/delete/:flight_id", a.delete_flight)
 	a.r.PUT("flight/user_confirm/:flight_id", a.user_confirm_flight)
 	a.r.PUT("flight/set_regions", a.set_flight_regions)
+	a.r.GET("flight/series/:series_id", a.get_flight_series)
+	a.r.PUT("flight/series/:series_id/cancel", a.cancel_flight_series)
 
 	//a.r.Use(a.WithAuthCheck(role.Moderator, role.Admin)).PUT("region/delete_restore/:region_name", a.delete_restore_region)
 	a.r.Use(a.WithAuthCheck(role.Moderator, role.Admin)).POST("region/add_image/:region_id", a.add_image)
 	a.r.PUT("flight/moderator_confirm", a.mod_confirm_flight)
+	a.r.PUT("flight/moderator_confirm/batch", a.mod_confirm_flights)
+	a.r.GET("flight/:flight_id/conflicts", a.get_flight_conflicts)
+	a.r.PUT("flight/series/:series_id/moderator_confirm", a.mod_confirm_flight_series)
+	a.r.PUT("flight/:flight_id/claim", a.claim_flight)
+	a.r.DELETE("flight/:flight_id/claim", a.release_flight)
 	a.r.DELETE("region/delete/:region_name", a.delete_region)
 	a.r.PUT("region/edit", a.edit_region)
 	a.r.POST("region/add", a.add_region)
+	a.r.POST("restrictions", a.add_restriction)
+	a.r.PUT("restriction/:restriction_id/lift", a.lift_restriction)
+
+	a.r.Use(a.WithAuthCheck(role.Admin)).GET("outbox/dead", a.get_dead_letters)
+	a.r.PUT("outbox/:message_id/retry", a.retry_outbox_message)
+	a.r.POST("districts", a.add_district)
+	a.r.PUT("district/:district_id", a.edit_district)
 
 	a.r.Run(":80")
 
@@ -125,7 +203,7 @@ func (a *Application) StartServer() {
 // @Produce json
 // @Success 200 {} json
 // @Param name_pattern query string false "Паттерн имени региона"
-// @Param district query string false "Округ"
+// @Param district query string false "Название округа"
 // @Param status query string false "Статус региона (Действует/Недействителен)"
 // @Router /regions [get]
 
@@ -166,6 +244,7 @@ func (a *Application) get_regions(c *gin.Context) {
 
 // @Summary      Добавить регион в БД
 // @Description  Создаёт новый регион с праметрами, описанными в json'е
+// @Description  Если задана граница Boundary (GeoJSON Polygon/MultiPolygon), AreaKm, центр и рамка считаются по ней
 // @Tags Регионы
 // @Accept json
 // @Produce      json
@@ -175,28 +254,55 @@ func (a *Application) get_regions(c *gin.Context) {
 func (a *Application) add_region(c *gin.Context) {
 	var region ds.Region
 
-	if err := c.BindJSON(&region); err != nil || region.Name == "" || region.Status == "" {
+	if err := c.BindJSON(&region); err != nil {
 		c.String(http.StatusBadRequest, "Невозможно распознать регион\n"+err.Error())
 		return
 	}
 
+	if region.Name == "" || region.Status == "" {
+		c.String(http.StatusBadRequest, "Нужно передать название и статус региона")
+		return
+	}
+
 	if region.Status == "" {
 		region.Status = "Черновик"
 	}
 
+	if err := ds.ValidateOperatingHours(region.OpensAt, region.ClosesAt); err != nil {
+		c.String(http.StatusBadRequest, "Часы работы региона задаются как HH:MM\n"+err.Error())
+		return
+	}
+
+	if err := ds.ValidateCeiling(region.MaxAltitude); err != nil {
+		c.String(http.StatusBadRequest, "Потолок полётов над регионом не может быть отрицательным")
+		return
+	}
+
 	err := a.repo.CreateRegion(region)
 
+	if errors.Is(err, ds.ErrUnknownDistrict) {
+		c.String(http.StatusBadRequest, "Такого округа нет")
+		return
+	}
+
+	if errors.Is(err, geo.ErrInvalidGeometry) {
+		c.String(http.StatusBadRequest, "Некорректная граница региона\n"+err.Error())
+		return
+	}
+
 	if err != nil {
 		c.String(http.StatusNotFound, "Невозможно создать регион\n"+err.Error())
 		return
 	}
 
+	a.afterRegionChange(c.Request.Context())
+
 	c.String(http.StatusCreated, "Регион был успешно создан")
 
 }
 
 // @Summary      Получить регион
-// @Description  Возвращает регион по имени
+// @Description  Возвращает регион по имени, в том числе потолок полётов MaxAltitude (м над землёй, 0 - без ограничения)
 // @Tags         Регионы
 // @Produce      json
 // @Param region path string true "Имя региона"
@@ -213,33 +319,75 @@ func (a *Application) get_region(c *gin.Context) {
 		return
 	}
 
+	setETag(c, found_region.Version)
 	c.JSON(http.StatusOK, found_region)
 
 }
 
 // @Summary      Отредактировать регион
 // @Description  Находит регион по имени и обновляет его поля
+// @Description  Если задана граница Boundary (GeoJSON Polygon/MultiPolygon), AreaKm, центр и рамка считаются по ней
 // @Tags         Регионы
 // @Accept json
 // @Produce      json
 // @Success      302  {object}  string
 // @Param region body ds.Region true "Новые данные изменяемого региона (должно быть имя региона или его id)"
+// @Param If-Match header string true "ETag, полученный вместе с регионом"
+// @Failure      412  {object}  string "Регион уже изменили"
 // @Router       /region/edit [put]
 func (a *Application) edit_region(c *gin.Context) {
-	var region *ds.Region
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+
+	var region ds.Region
 
 	if err := c.BindJSON(&region); err != nil {
 		c.Error(err)
 		return
 	}
 
-	err := a.repo.EditRegion(region)
+	if region.Name == "" {
+		c.String(http.StatusBadRequest, "Не указано название региона")
+		return
+	}
+
+	if err := ds.ValidateCeiling(region.MaxAltitude); err != nil {
+		c.String(http.StatusBadRequest, "Потолок полётов над регионом не может быть отрицательным")
+		return
+	}
+
+	region.Version = version
+	err := a.repo.EditRegion(&region)
+
+	if errors.Is(err, ds.ErrVersionMismatch) {
+		c.String(http.StatusPreconditionFailed, "Регион уже изменили, получите его заново")
+		return
+	}
+
+	if errors.Is(err, ds.ErrUnknownDistrict) {
+		c.String(http.StatusBadRequest, "Такого округа нет")
+		return
+	}
+
+	if errors.Is(err, ds.ErrInvalidOperatingHours) {
+		c.String(http.StatusBadRequest, "Часы работы региона задаются как HH:MM\n"+err.Error())
+		return
+	}
+
+	if errors.Is(err, geo.ErrInvalidGeometry) {
+		c.String(http.StatusBadRequest, "Некорректная граница региона\n"+err.Error())
+		return
+	}
 
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
+	a.afterRegionChange(c.Request.Context())
+
 	c.String(http.StatusCreated, "Region was successfuly edited")
 
 }
@@ -268,6 +416,8 @@ func (a *Application) delete_region(c *gin.Context) {
 		return
 	}
 
+	a.afterRegionChange(c.Request.Context())
+
 	c.String(http.StatusFound, "Регион был успешно удалён")
 }
 
@@ -286,16 +436,37 @@ func (a *Application) book(c *gin.Context) {
 		return
 	}
 
+	_userRole, _ := c.Get("role")
+
 	userUUID := _userUUID.(uuid.UUID)
-	err := a.repo.Book(request_body, userUUID)
+	userRole := _userRole.(role.Role)
+
+	var route *ds.FlightRoute
+	if request_body.Route != nil {
+		var err error
+		route, err = a.planRoute(c.Request.Context(), *request_body.Route)
+		if err != nil {
+			c.String(flightErrorStatus(err), "Не могу проложить маршрут\n"+err.Error())
+			return
+		}
+	}
+
+	conflicts, restrictions, err := a.repo.Book(request_body, route, userUUID, userRole, a.hours)
+	if respondConflicts(c, err) || respondRestrictions(c, err) {
+		return
+	}
 
 	if err != nil {
 		c.Error(err)
-		c.String(http.StatusNotFound, "Не могу забронировать регион")
+		c.String(flightErrorStatus(err), "Не могу забронировать регион\n"+err.Error())
 		return
 	}
 
-	c.String(http.StatusCreated, "Бронирование прошло успешно!")
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Бронирование прошло успешно!",
+		"conflicts":    conflicts,
+		"restrictions": restrictions,
+	})
 }
 
 // @Summary      Получить заявки
@@ -304,6 +475,7 @@ func (a *Application) book(c *gin.Context) {
 // @Produce      json
 // @Success      302  {object}  string
 // @Param status query string false "Статус заявок"
+// @Param overrun query bool false "Только полёты, не сообщившие о посадке вовремя"
 // @Router       /flights [get]
 func (a *Application) get_flights(c *gin.Context) {
 	_roleNumber, _ := c.Get("role")
@@ -316,35 +488,68 @@ func (a *Application) get_flights(c *gin.Context) {
 	startDate := c.Query("startDate")
 	endDate := c.Query("endDate")
 
-	flights, err := a.repo.GetFlights(status, startDate, endDate, roleNumber, userUUID)
+	overrunOnly := c.Query("overrun") == "true"
+
+	flights, err := a.repo.GetFlights(status, startDate, endDate, overrunOnly, roleNumber, userUUID)
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
 	clean_flights := []ds.FlightNoUser{}
+	claimants := a.flightClaimants(c.Request.Context(), flights)
 
 	for _, flight := range flights {
-		clean_flights = append(clean_flights, ds.FlightNoUser{
-			ID:            flight.ID,
-			Status:        flight.Status,
-			DateCreated:   flight.DateCreated,
-			DateProcessed: flight.DateProcessed,
-			DateFinished:  flight.DateFinished,
-			TakeoffDate:   flight.TakeoffDate,
-			ArrivalDate:   flight.ArrivalDate,
-			Moderator:     flight.Moderator.Name,
-			User:          flight.User.Name,
-			AllowedHours:  flight.AllowedHours,
-		})
+		clean_flight := flightNoUser(flight)
+		clean_flight.ClaimedBy = claimants[flight.ID]
+		clean_flights = append(clean_flights, clean_flight)
 	}
 
 	c.JSON(http.StatusOK, clean_flights)
 }
 
+func flightNoUser(flight ds.Flight) ds.FlightNoUser {
+	allowed_hours := []ds.TimeInterval(flight.AllowedHours)
+	if allowed_hours == nil {
+		allowed_hours = []ds.TimeInterval{}
+	}
+	uncovered := ds.UncoveredHours(allowed_hours, flight.TakeoffDate, flight.ArrivalDate)
+
+	return ds.FlightNoUser{
+		ID:               flight.ID,
+		Status:           flight.Status,
+		DateCreated:      flight.DateCreated,
+		DateProcessed:    flight.DateProcessed,
+		DateFinished:     flight.DateFinished,
+		TakeoffDate:      flight.TakeoffDate,
+		ArrivalDate:      flight.ArrivalDate,
+		Moderator:        flight.Moderator.Name,
+		User:             flight.User.Name,
+		AllowedHours:     allowed_hours,
+		HoursCovered:     len(uncovered) == 0,
+		UncoveredHours:   uncovered,
+		SeriesID:         flight.SeriesRefer,
+		ParentID:         flight.ParentRefer,
+		RejectionCode:    flight.RejectionCode,
+		RejectionReason:  flight.RejectionReason,
+		ModeratorComment: flight.ModeratorComment,
+		ActualTakeoff:    flight.ActualTakeoff,
+		ActualLanding:    flight.ActualLanding,
+		Overrun:          flight.Overrun,
+		MinAltitude:      flight.MinAltitude,
+		MaxAltitude:      flight.MaxAltitude,
+		Route:            flight.Route,
+		CorridorWidth:    flight.CorridorWidth,
+		Version:          flight.Version,
+	}
+}
+
 type getFlightResp struct {
-	Flight  ds.FlightNoUser
-	Regions []string
+	Flight       ds.FlightNoUser
+	Regions      []string
+	Legs         []ds.FlightLeg
+	Revision     *ds.FlightRevisionDiff `json:",omitempty"` // правки относительно отклонённой заявки
+	Restrictions []ds.RestrictionHit    // ограничения полётов, которые задевает заявка
 }
 
 // @Summary      Получить заявку
@@ -384,32 +589,357 @@ func (a *Application) get_flight(c *gin.Context) {
 		regions_arr = append(regions_arr, flight_region.Name)
 	}
 
+	flight_legs, err := a.repo.GetFlightLegs(int(found_flight.ID))
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Не могу получить участки маршрута заявки")
+		return
+	}
+
+	revision, err := a.repo.GetFlightRevisionDiff(found_flight)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Не могу сравнить заявку с отклонённой")
+		return
+	}
+
+	restrictions, err := a.repo.GetFlightRestrictions(int(found_flight.ID))
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Не могу проверить ограничения полётов")
+		return
+	}
+
+	setETag(c, found_flight.Version)
 	c.JSON(http.StatusOK, getFlightResp{
-		Flight: ds.FlightNoUser{
-			ID:            flight.ID,
-			Status:        flight.Status,
-			DateCreated:   flight.DateCreated,
-			DateProcessed: flight.DateProcessed,
-			DateFinished:  flight.DateFinished,
-			TakeoffDate:   flight.TakeoffDate,
-			ArrivalDate:   flight.ArrivalDate,
-			Moderator:     flight.Moderator.Name,
-			User:          flight.User.Name,
-			AllowedHours:  flight.AllowedHours,
-		},
-		Regions: regions_arr,
+		Flight:       flightNoUser(found_flight),
+		Regions:      regions_arr,
+		Legs:         flight_legs,
+		Revision:     revision,
+		Restrictions: restrictions,
+	})
+}
+
+// @Summary      Получить историю статусов заявки
+// @Description  Возвращает все смены статуса заявки в хронологическом порядке: кто, когда, из какого статуса в какой и почему
+// @Tags         Заявки
+// @Produce      json
+// @Success      200  {array}  ds.FlightStatusEventNoUser
+// @Param flight_id path int true "id заявки"
+// @Router       /flight/{flight_id}/history [get]
+func (a *Application) get_flight_history(c *gin.Context) {
+	flight_id, err := strconv.Atoi(c.Param("flight_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Передан некорректный ID полёта")
+		return
+	}
+
+	_userUUID, _ := c.Get("userUUID")
+	_userRole, _ := c.Get("role")
+	userUUID := _userUUID.(uuid.UUID)
+	userRole := _userRole.(role.Role)
+
+	flight, err := a.repo.GetFlightByID(flight_id)
+	if err != nil {
+		c.String(flightErrorStatus(err), "Не могу найти заявку")
+		return
+	}
+
+	if userRole == role.User && (flight.UserRefer == nil || *flight.UserRefer != userUUID) {
+		c.String(http.StatusForbidden, "Это не ваша заявка")
+		return
+	}
+
+	events, err := a.repo.GetFlightHistory(flight_id)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Не могу получить историю заявки")
+		return
+	}
+
+	actor_ids := []uuid.UUID{}
+	for _, event := range events {
+		if event.ActorRefer != nil {
+			actor_ids = append(actor_ids, *event.ActorRefer)
+		}
+	}
+
+	names, err := a.repo.GetUserNames(actor_ids)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Не могу получить историю заявки")
+		return
+	}
+
+	timeline := []ds.FlightStatusEventNoUser{}
+	for _, event := range events {
+		actor := ""
+		if event.ActorRefer != nil {
+			actor = names[*event.ActorRefer]
+		}
+
+		timeline = append(timeline, ds.FlightStatusEventNoUser{
+			FromStatus:  event.FromStatus,
+			ToStatus:    event.ToStatus,
+			Actor:       actor,
+			ActorRole:   event.ActorRole,
+			Reason:      event.Reason,
+			DateCreated: event.DateCreated,
+		})
+	}
+
+	c.JSON(http.StatusOK, timeline)
+}
+
+// @Summary      Скопировать заявку в черновик
+// @Description  Копирует регионы и длительность заявки в черновик текущего пользователя, сдвигая взлёт на TakeoffDate.
+// @Description  Если черновик уже есть, то при Merge регионы дописываются в него, иначе возвращается 409.
+// @Tags         Заявки
+// @Accept       json
+// @Produce      json
+// @Success      201  {object}  string
+// @Failure      409  {object}  string "У пользователя уже есть черновик"
+// @Param flight_id path int true "id исходной заявки"
+// @Param request_body body ds.CloneFlightRequestBody true "Новое время взлёта"
+// @Router       /flight/{flight_id}/clone [post]
+func (a *Application) clone_flight(c *gin.Context) {
+	flight_id, err := strconv.Atoi(c.Param("flight_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Передан некорректный ID полёта")
+		return
+	}
+
+	var requestBody ds.CloneFlightRequestBody
+	if err := c.BindJSON(&requestBody); err != nil {
+		c.String(http.StatusBadRequest, "Передан плохой json")
+		return
+	}
+
+	takeoff_date, err := time.Parse(time.RFC3339, requestBody.TakeoffDate)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Не могу распознать время взлёта")
+		return
+	}
+
+	_userUUID, _ := c.Get("userUUID")
+	_userRole, _ := c.Get("role")
+	userUUID := _userUUID.(uuid.UUID)
+	userRole := _userRole.(role.Role)
+
+	draft, err := a.repo.CloneFlight(flight_id, takeoff_date, userUUID, userRole, requestBody.Merge)
+	if err != nil {
+		c.String(flightErrorStatus(err), "Не получается скопировать заявку\n"+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":   "Заявка скопирована в черновик",
+		"flight_id": draft.ID,
+	})
+}
+
+// @Summary      Повторно подать отклонённую заявку
+// @Description  Создаёт новую редакцию отклонённой заявки и отправляет её на модерацию. Незаполненные даты и маршрут
+// @Description  берутся из отклонённой заявки; модератор увидит, что изменилось, в Revision ответа /flight.
+// @Tags         Заявки
+// @Accept       json
+// @Produce      json
+// @Success      201  {object}  string
+// @Failure      409  {object}  string "Заявка не отклонена или уже подана повторно"
+// @Param flight_id path int true "id отклонённой заявки"
+// @Param request_body body ds.ResubmitFlightRequestBody true "Правки"
+// @Router       /flight/{flight_id}/resubmit [put]
+func (a *Application) resubmit_flight(c *gin.Context) {
+	flight_id, err := strconv.Atoi(c.Param("flight_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Передан некорректный ID полёта")
+		return
+	}
+
+	var requestBody ds.ResubmitFlightRequestBody
+	if err := c.BindJSON(&requestBody); err != nil {
+		c.String(http.StatusBadRequest, "Передан плохой json")
+		return
+	}
+
+	var takeoff_date, arrival_date time.Time
+	if requestBody.TakeoffDate != "" {
+		takeoff_date, err = time.Parse(time.RFC3339, requestBody.TakeoffDate)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Не могу распознать время взлёта")
+			return
+		}
+	}
+	if requestBody.ArrivalDate != "" {
+		arrival_date, err = time.Parse(time.RFC3339, requestBody.ArrivalDate)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Не могу распознать время посадки")
+			return
+		}
+	}
+
+	_userUUID, _ := c.Get("userUUID")
+	_userRole, _ := c.Get("role")
+	userUUID := _userUUID.(uuid.UUID)
+	userRole := _userRole.(role.Role)
+
+	revision, conflicts, err := a.repo.ResubmitFlight(flight_id, takeoff_date, arrival_date, requestBody.Regions, requestBody.Legs, userUUID, userRole, a.hours)
+	if respondConflicts(c, err) || respondRestrictions(c, err) {
+		return
+	}
+
+	if err != nil {
+		c.String(flightErrorStatus(err), "Не получается повторно подать заявку\n"+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":   "Заявка повторно отправлена на модерацию",
+		"flight_id": revision.ID,
+		"conflicts": conflicts,
 	})
 }
 
+// @Summary      Отменить одобренную заявку
+// @Description  Отменяет заявку в статусе "Завершён" до взлёта. Причина обязательна, одобривший заявку модератор получит уведомление.
+// @Tags         Заявки
+// @Accept       json
+// @Produce      json
+// @Success      201  {object}  string
+// @Failure      409  {object}  string "Заявка не одобрена или полёт уже начался"
+// @Param flight_id path int true "id заявки"
+// @Param request_body body ds.CancelFlightRequestBody true "Причина отмены"
+// @Router       /flight/{flight_id}/cancel [put]
+func (a *Application) cancel_flight(c *gin.Context) {
+	flight_id, err := strconv.Atoi(c.Param("flight_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Передан некорректный ID полёта")
+		return
+	}
+
+	var requestBody ds.CancelFlightRequestBody
+	if err := c.BindJSON(&requestBody); err != nil {
+		c.String(http.StatusBadRequest, "Передан плохой json")
+		return
+	}
+
+	_userUUID, _ := c.Get("userUUID")
+	_userRole, _ := c.Get("role")
+	userUUID := _userUUID.(uuid.UUID)
+	userRole := _userRole.(role.Role)
+
+	err = a.repo.CancelFlight(flight_id, userUUID, userRole, requestBody.Reason)
+	if err != nil {
+		c.String(flightErrorStatus(err), "Не получается отменить заявку\n"+err.Error())
+		return
+	}
+
+	c.String(http.StatusCreated, "Заявка отменена")
+}
+
+// @Summary      Сообщить о взлёте
+// @Description  Отмечает фактический взлёт по одобренной заявке. Взлёт возможен только в одобренное окно полёта.
+// @Tags         Заявки
+// @Accept       json
+// @Produce      json
+// @Success      201  {object}  string
+// @Failure      409  {object}  string "Заявка не одобрена или время вне окна полёта"
+// @Param flight_id path int true "id заявки"
+// @Param request_body body ds.FlightReportRequestBody false "Фактическое время взлёта"
+// @Router       /flight/{flight_id}/takeoff [put]
+func (a *Application) report_takeoff(c *gin.Context) {
+	flight_id, at, ok := parseFlightReport(c)
+	if !ok {
+		return
+	}
+
+	_userUUID, _ := c.Get("userUUID")
+	_userRole, _ := c.Get("role")
+	userUUID := _userUUID.(uuid.UUID)
+	userRole := _userRole.(role.Role)
+
+	err := a.repo.ReportTakeoff(flight_id, at, userUUID, userRole)
+	if err != nil {
+		c.String(flightErrorStatus(err), "Не получается отметить взлёт\n"+err.Error())
+		return
+	}
+
+	c.String(http.StatusCreated, "Взлёт отмечен")
+}
+
+// @Summary      Сообщить о посадке
+// @Description  Отмечает фактическую посадку полёта
+// @Tags         Заявки
+// @Accept       json
+// @Produce      json
+// @Success      201  {object}  string
+// @Failure      409  {object}  string "Полёт не в воздухе"
+// @Param flight_id path int true "id заявки"
+// @Param request_body body ds.FlightReportRequestBody false "Фактическое время посадки"
+// @Router       /flight/{flight_id}/landing [put]
+func (a *Application) report_landing(c *gin.Context) {
+	flight_id, at, ok := parseFlightReport(c)
+	if !ok {
+		return
+	}
+
+	_userUUID, _ := c.Get("userUUID")
+	_userRole, _ := c.Get("role")
+	userUUID := _userUUID.(uuid.UUID)
+	userRole := _userRole.(role.Role)
+
+	err := a.repo.ReportLanding(flight_id, at, userUUID, userRole)
+	if err != nil {
+		c.String(flightErrorStatus(err), "Не получается отметить посадку\n"+err.Error())
+		return
+	}
+
+	c.String(http.StatusCreated, "Посадка отмечена")
+}
+
+// parseFlightReport разбирает id заявки и фактическое время из запроса; тело можно не передавать
+func parseFlightReport(c *gin.Context) (int, time.Time, bool) {
+	flight_id, err := strconv.Atoi(c.Param("flight_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Передан некорректный ID полёта")
+		return 0, time.Time{}, false
+	}
+
+	var requestBody ds.FlightReportRequestBody
+	if c.Request.ContentLength > 0 {
+		if err := c.BindJSON(&requestBody); err != nil {
+			c.String(http.StatusBadRequest, "Передан плохой json")
+			return 0, time.Time{}, false
+		}
+	}
+
+	at := time.Now()
+	if requestBody.Date != "" {
+		at, err = time.Parse(time.RFC3339, requestBody.Date)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Не могу распознать время")
+			return 0, time.Time{}, false
+		}
+		if at.After(time.Now()) {
+			c.String(http.StatusBadRequest, "Нельзя сообщить о событии из будущего")
+			return 0, time.Time{}, false
+		}
+	}
+
+	return flight_id, at, true
+}
+
 // @Summary      Отредактировать заявку
 // @Description  Находит заявку и обновляет её поля
 // @Tags         Заявки
 // @Accept json
 // @Produce      json
 // @Success      201  {object}  string
-// @Param flight body ds.Flight false "Заявка"
+// @Param flight body ds.EditFlightRequestBody false "Заявка"
+// @Param If-Match header string true "ETag, полученный вместе с заявкой"
+// @Failure      412  {object}  string "Заявку уже изменили"
 // @Router       /flight/edit [put]
 func (a *Application) edit_flight(c *gin.Context) {
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+
 	var requestBody ds.EditFlightRequestBody
 
 	if err := c.BindJSON(&requestBody); err != nil {
@@ -418,17 +948,23 @@ func (a *Application) edit_flight(c *gin.Context) {
 		return
 	}
 
-	// _userUUID, _ := c.Get("userUUID")
-	// userUUID := _userUUID.(uuid.UUID)
+	_userUUID, _ := c.Get("userUUID")
+	_userRole, _ := c.Get("role")
+
+	userUUID := _userUUID.(uuid.UUID)
+	userRole := _userRole.(role.Role)
 
 	var flight = ds.Flight{}
 	flight.ArrivalDate = requestBody.ArrivalDate.Add(-3 * time.Hour)
 	flight.TakeoffDate = requestBody.TakeoffDate.Add(-3 * time.Hour)
 	flight.ID = uint(requestBody.FlightID)
-	err := a.repo.EditFlight(&flight)
+	flight.MinAltitude = requestBody.MinAltitude
+	flight.MaxAltitude = requestBody.MaxAltitude
+	flight.Version = version
+	err := a.repo.EditFlight(&flight, userUUID, userRole)
 
 	if err != nil {
-		c.Error(err)
+		c.String(flightErrorStatus(err), "Не получается обновить заявку\n"+err.Error())
 		return
 	}
 
@@ -460,22 +996,41 @@ func (a *Application) set_flight_regions(c *gin.Context) {
 		return
 	}
 
-	err := a.repo.SetFlightRegions(requestBody.FlightID, requestBody.Regions)
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+
+	_userUUID, _ := c.Get("userUUID")
+	_userRole, _ := c.Get("role")
+
+	userUUID := _userUUID.(uuid.UUID)
+	userRole := _userRole.(role.Role)
+
+	conflicts, err := a.repo.SetFlightRegions(requestBody.FlightID, requestBody.Regions, requestBody.Legs, version, userUUID, userRole)
+	if respondConflicts(c, err) {
+		return
+	}
+
 	if err != nil {
-		c.String(http.StatusInternalServerError, "Не получилось задать регионы для заявки\n"+err.Error())
+		c.String(flightErrorStatus(err), "Не получилось задать регионы для заявки\n"+err.Error())
+		return
 	}
 
-	c.String(http.StatusCreated, "Регионы заявки успешно заданы!")
+	c.JSON(http.StatusCreated, gin.H{
+		"message":   "Регионы заявки успешно заданы!",
+		"conflicts": conflicts,
+	})
 
 }
 
 // @Summary Изменить статус заявки
-// @Description Получает id заявки и новый статус и производит необходимые обновления
-// @Tags Заявки
+// @Description Получает id заявки и новый статус и производит необходимые обновления. Доступны только удаление и отмена, остальные переходы выполняются своими эндпоинтами
 // @Tags Заявки
 // @Accept json
 // @Produce json
 // @Success 201 {object} string
+// @Failure 409 {object} string "Недопустимый переход статуса"
 // @Param request_body body ds.ChangeFlightStatusRequestBody true "Тело запроса"
 // @Router /flight/status_change [put]
 func (a *Application) flight_status_change(c *gin.Context) {
@@ -492,44 +1047,39 @@ func (a *Application) flight_status_change(c *gin.Context) {
 	userUUID := _userUUID.(uuid.UUID)
 	userRole := _userRole.(role.Role)
 
+	new_status, err := ds.ParseFlightStatus(requestBody.Status)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Передан неизвестный статус заявки")
+		return
+	}
+
 	status, err := a.repo.GetFlightStatus(requestBody.ID)
-	if err == nil {
-		c.Error(err)
+	if err != nil {
+		c.String(flightErrorStatus(err), "Не могу найти заявку\n"+err.Error())
 		return
 	}
 
-	if userRole == role.User && requestBody.Status == "Удалён" {
+	if userRole == role.User {
+		err = a.repo.ChangeFlightStatusUser(requestBody.ID, new_status, userUUID, requestBody.Reason)
+	} else {
+		err = a.repo.ChangeFlightStatus(requestBody.ID, new_status, userUUID, userRole, requestBody.Reason)
+	}
 
-		if status == "Черновик" || status == "Сформирован" {
-			err = a.repo.ChangeFlightStatusUser(requestBody.ID, requestBody.Status, userUUID)
+	if err != nil {
+		c.String(flightErrorStatus(err), "Не получается обновить статус заявки\n"+err.Error())
+		return
+	}
 
-			if err != nil {
-				c.Error(err)
-				return
-			} else {
-				c.String(http.StatusCreated, "Статус заявки был успешно обновлён")
-			}
-		}
-	} else {
-		err = a.repo.ChangeFlightStatus(requestBody.ID, requestBody.Status)
+	if userRole == role.Moderator && status == ds.Draft.String() {
+		err = a.repo.SetFlightModerator(requestBody.ID, userUUID)
 
 		if err != nil {
 			c.Error(err)
 			return
 		}
-
-		if userRole == role.Moderator && status == "Черновик" {
-			err = a.repo.SetFlightModerator(requestBody.ID, userUUID)
-
-			if err != nil {
-				c.Error(err)
-				return
-			}
-
-		}
-
-		c.String(http.StatusCreated, "Статус заявки был успешно обновлён")
 	}
+
+	c.String(http.StatusCreated, "Статус заявки был успешно обновлён")
 }
 
 // @Summary      Удалить заявку
@@ -547,10 +1097,16 @@ func (a *Application) flight_status_change(c *gin.Context) {
 func (a *Application) delete_flight(c *gin.Context) {
 	flight_id, _ := strconv.Atoi(c.Param("flight_id"))
 
-	err := a.repo.LogicalDeleteFlight(flight_id)
+	_userUUID, _ := c.Get("userUUID")
+	_userRole, _ := c.Get("role")
+
+	userUUID := _userUUID.(uuid.UUID)
+	userRole := _userRole.(role.Role)
+
+	err := a.repo.LogicalDeleteFlight(flight_id, userUUID, userRole)
 
 	if err != nil {
-		c.Error(err)
+		c.String(flightErrorStatus(err), "Не получается удалить заявку\n"+err.Error())
 		return
 	}
 
@@ -579,7 +1135,7 @@ func (a *Application) delete_flight_to_region(c *gin.Context) {
 	err = a.repo.DeleteFlightToRegion(flight_id, region_id)
 
 	if err != nil {
-		c.Error(err)
+		c.String(flightErrorStatus(err), "Не получается убрать регион из заявки\n"+err.Error())
 		return
 	}
 
@@ -746,6 +1302,21 @@ type setAllowedHoursReq struct {
 	allowedHours string
 }
 
+// @Summary      Решение модератора по заявке
+// @Description  Одобряет (confirm=True) или отклоняет (confirm=False) сформированную заявку.
+// @Description  При отклонении в теле обязательны Code (см. /flight/rejection_reasons) и Reason, Comment можно указать всегда.
+// @Tags         Заявки
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  string
+// @Failure      409  {object}  string "Пересечения, нехватка места или заявку рассматривает другой модератор"
+// @Param flight_id query int true "id заявки"
+// @Param confirm query string true "True/False"
+// @Param request_body body ds.ModConfirmFlightRequestBody false "Причина отклонения и комментарий"
+// @Param If-Match header string true "ETag, полученный вместе с заявкой"
+// @Failure      403  {object}  string "Заявку рассматривают модераторы её округов"
+// @Failure      412  {object}  string "Заявку уже изменили"
+// @Router       /flight/moderator_confirm [put]
 func (a *Application) mod_confirm_flight(c *gin.Context) {
 	id_param := c.Query("flight_id")
 	flight_id, err := strconv.Atoi(id_param)
@@ -755,6 +1326,11 @@ func (a *Application) mod_confirm_flight(c *gin.Context) {
 		return
 	}
 
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+
 	confirm_param := c.Query("confirm")
 	confirm := true
 	if confirm_param == "True" {
@@ -766,16 +1342,74 @@ func (a *Application) mod_confirm_flight(c *gin.Context) {
 		return
 	}
 
+	// тело необязательно: старые клиенты одобряют без него
+	var requestBody ds.ModConfirmFlightRequestBody
+	if c.Request.ContentLength > 0 {
+		if err := c.BindJSON(&requestBody); err != nil {
+			c.String(http.StatusBadRequest, "Передан плохой json")
+			return
+		}
+	}
+
 	_userUUID, _ := c.Get("userUUID")
+	_userRole, _ := c.Get("role")
 	userUUID := _userUUID.(uuid.UUID)
+	userRole := _userRole.(role.Role)
+
+	conflicts, err := a.decideFlight(c.Request.Context(), userUUID, userRole, flight_id, ds.ModerationDecision{
+		Confirm: confirm,
+		Code:    requestBody.Code,
+		Reason:  requestBody.Reason,
+		Comment: requestBody.Comment,
+		Version: version,
+	})
+	if respondConflicts(c, err) || respondRestrictions(c, err) || a.respondClaimed(c, err) {
+		return
+	}
+
+	var capacityErr *ds.CapacityError
+	if errors.As(err, &capacityErr) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("Над регионом %s допускается не более %d одновременных полётов, а с %s по %s уже одобрено %d",
+				capacityErr.RegionName, capacityErr.Capacity,
+				capacityErr.From.Format(time.RFC3339), capacityErr.To.Format(time.RFC3339), capacityErr.Occupied),
+			"capacity": capacityErr,
+		})
+		return
+	}
 
-	err = a.repo.ModConfirmFlight(userUUID, flight_id, confirm)
 	if err != nil {
-		c.String(http.StatusInternalServerError, "Не получается обновить статус!")
+		c.String(flightErrorStatus(err), "Не получается обновить статус!\n"+err.Error())
 		return
 	}
 
-	c.String(http.StatusOK, "Статус обновлён!")
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Статус обновлён!",
+		"conflicts": conflicts,
+	})
+}
+
+// @Summary      Получить пересечения заявки
+// @Description  Возвращает сформированные и одобренные заявки, которые пересекаются с данной по региону и времени
+// @Tags         Заявки
+// @Produce      json
+// @Success      200  {array}  ds.FlightConflict
+// @Param flight_id path int true "id заявки"
+// @Router       /flight/{flight_id}/conflicts [get]
+func (a *Application) get_flight_conflicts(c *gin.Context) {
+	flight_id, err := strconv.Atoi(c.Param("flight_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Передан некорректный ID полёта")
+		return
+	}
+
+	conflicts, err := a.repo.GetFlightConflicts(flight_id)
+	if err != nil {
+		c.String(flightErrorStatus(err), "Не могу получить пересечения заявки")
+		return
+	}
+
+	c.JSON(http.StatusOK, conflicts)
 }
 
 type AllowedHoursReq struct {
@@ -793,29 +1427,17 @@ func (a *Application) user_confirm_flight(c *gin.Context) {
 	}
 
 	_userUUID, _ := c.Get("userUUID")
+	_userRole, _ := c.Get("role")
 	userUUID := _userUUID.(uuid.UUID)
+	userRole := _userRole.(role.Role)
 
-	err = a.repo.UserConfirmFlight(userUUID, flight_id)
-	if err != nil {
-		c.String(http.StatusInternalServerError, "Не получается обновить статус!")
-		return
-	}
-
-	jwtStr := c.GetHeader("Authorization")
-	jwtPrefix := "Bearer "
-	jwtStr = jwtStr[len(jwtPrefix):]
-
-	url := "http://127.0.0.1:8000/allowed_hours/"
-
-	jsonPayload := []byte(`{"pk": "` + strconv.Itoa(flight_id) + `", "token": "` + jwtStr + `"}`)
-
-	_, err = http.Post(url, "application/json",
-		bytes.NewBuffer(jsonPayload))
+	err = a.repo.UserConfirmFlight(userUUID, userRole, flight_id, a.hours)
 	if err != nil {
-		fmt.Println("Error sending POST request:", err)
+		c.String(flightErrorStatus(err), "Не получается обновить статус!\n"+err.Error())
 		return
 	}
 
+	// разрешённые часы либо уже посчитаны, либо запрос уйдёт в сервис через outbox после коммита
 	c.String(http.StatusOK, "Статус обновлён!")
 }
 
@@ -835,6 +1457,8 @@ func (a *Application) add_region_to_flight(c *gin.Context) {
 		return
 	}
 	userUUID := _userUUID.(uuid.UUID)
+	_userRole, _ := c.Get("role")
+	userRole := _userRole.(role.Role)
 
 	draft, err := a.repo.GetDraftFlight(userUUID)
 	if err != nil {
@@ -845,10 +1469,11 @@ func (a *Application) add_region_to_flight(c *gin.Context) {
 		new_draft := ds.Flight{}
 		new_draft.UserRefer = &userUUID
 		new_draft.DateCreated = time.Now()
-		new_draft.Status = "Черновик"
+		new_draft.Status = ds.Draft.String()
 		new_draft.ModeratorRefer = nil
-		err := a.repo.CreateFlight(new_draft)
-		if err != nil {
+		err := a.repo.CreateFlight(new_draft, userRole)
+		// черновик мог только что создать параллельный запрос, тогда дописываем регион в него
+		if err != nil && !errors.Is(err, ds.ErrDraftExists) {
 			c.String(http.StatusInternalServerError, "Не могу создать черновую заявку!")
 			return
 		}
@@ -865,28 +1490,37 @@ func (a *Application) add_region_to_flight(c *gin.Context) {
 
 	err = a.repo.CreateFlightToRegion(region_to_draft)
 	if err != nil {
-		c.String(http.StatusInternalServerError, "Не могу связать район с полётом!")
+		c.String(flightErrorStatus(err), "Не могу связать район с полётом!\n"+err.Error())
+		return
 	}
 
 	c.String(http.StatusOK, "Район добавлен в черновой полёт!")
 
 }
 
+// @Summary      Выставить разрешённые часы
+// @Description  Обратный вызов сервиса расчёта разрешённых часов. Запрос подписывается HMAC-SHA256 от "X-Timestamp.тело"
+// @Description  общим секретом; принимается только для заявок в статусе "Сформирован".
+// @Tags         Заявки
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  string
+// @Failure      401  {object}  string "Неверная или повторная подпись"
+// @Failure      409  {object}  string "Заявка не ждёт разрешённых часов"
+// @Param X-Timestamp header string true "unix-время запроса"
+// @Param X-Signature header string true "hex HMAC-SHA256"
+// @Param request_body body ds.SetAllowedHoursRequestBody true "Разрешённые часы"
+// @Router       /flight/set_allowed_hours [put]
 func (a *Application) set_allowed_hours(c *gin.Context) {
-
-	log.Println(c.Query("allowed_hours"), c.Query("id"))
-
-	// _userUUID, _ := c.Get("userUUID")
-	// userUUID := _userUUID.(uuid.UUID)
-
-	flight_id, err := strconv.Atoi(c.Query("id"))
-	if err != nil {
-		c.AbortWithError(http.StatusBadRequest, err)
+	var requestBody ds.SetAllowedHoursRequestBody
+	if err := c.BindJSON(&requestBody); err != nil {
+		c.String(http.StatusBadRequest, "Передан плохой json")
+		return
 	}
 
-	err = a.repo.SetAllowedHours(flight_id, c.Query("allowed_hours"))
+	err := a.repo.SetAllowedHours(requestBody.FlightID, requestBody.AllowedHours)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		c.String(flightErrorStatus(err), "Не могу выставить разрешённые часы\n"+err.Error())
 		return
 	}
 
@@ -940,6 +1574,79 @@ func (a *Application) add_image(c *gin.Context) {
 
 }
 
+// flightErrorStatus подбирает http-код для ошибок изменения заявки
+func flightErrorStatus(err error) int {
+	var transitionErr *ds.TransitionError
+	var conflictErr *ds.ConflictError
+	var capacityErr *ds.CapacityError
+	var claimErr *ds.ClaimError
+	var restrictionErr *ds.RestrictionError
+	var altitudeErr *ds.AltitudeError
+
+	switch {
+	case errors.As(err, &transitionErr), errors.As(err, &conflictErr), errors.As(err, &capacityErr),
+		errors.As(err, &claimErr), errors.As(err, &restrictionErr), errors.As(err, &altitudeErr), errors.Is(err, ds.ErrDraftExists), errors.Is(err, ds.ErrAlreadyResubmitted),
+		errors.Is(err, ds.ErrFlightDeparted), errors.Is(err, ds.ErrOutsideFlightWindow), errors.Is(err, ds.ErrFlightNotFormed),
+		errors.Is(err, ds.ErrDedicatedTransition), errors.Is(err, ds.ErrFlightNotDraft):
+		return http.StatusConflict
+	case errors.Is(err, ds.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, ds.ErrVersionRequired):
+		return http.StatusPreconditionRequired
+	case errors.Is(err, ds.ErrFlightNotOwned), errors.Is(err, ds.ErrNotDistrictModerator):
+		return http.StatusForbidden
+	case errors.Is(err, ds.ErrUnknownFlightStatus), errors.Is(err, ds.ErrDraftSeries), errors.Is(err, ds.ErrInvalidRecurrence),
+		errors.Is(err, ds.ErrInvalidLegs), errors.Is(err, ds.ErrRejectionReason), errors.Is(err, ds.ErrCancelReason),
+		errors.Is(err, ds.ErrInvalidAllowedHours), errors.Is(err, ds.ErrRouteOutsideRegions), errors.Is(err, geo.ErrInvalidGeometry),
+		errors.Is(err, ds.ErrInvalidRestriction), errors.Is(err, ds.ErrInvalidAltitude):
+		return http.StatusBadRequest
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
+
+// newFlightActionResult дополняет результат массовой операции HTTP-кодом ошибки
+func newFlightActionResult(flight ds.Flight, to ds.FlightStatus, err error) ds.FlightActionResult {
+	result := ds.NewFlightActionResult(flight, to, err)
+	if err != nil {
+		result.StatusCode = flightErrorStatus(err)
+	}
+
+	return result
+}
+
+// respondConflicts отвечает 409 со списком пересечений, если заявка упёрлась в запрет пересечений
+func respondConflicts(c *gin.Context, err error) bool {
+	var conflictErr *ds.ConflictError
+	if !errors.As(err, &conflictErr) {
+		return false
+	}
+
+	c.JSON(http.StatusConflict, gin.H{
+		"error":     "Заявка пересекается с другими полётами в регионах, где это запрещено",
+		"conflicts": conflictErr.Conflicts,
+	})
+
+	return true
+}
+
+// respondRestrictions отвечает 409 со списком ограничений, если заявка задела блокирующее ограничение полётов
+func respondRestrictions(c *gin.Context, err error) bool {
+	var restrictionErr *ds.RestrictionError
+	if !errors.As(err, &restrictionErr) {
+		return false
+	}
+
+	c.JSON(http.StatusConflict, gin.H{
+		"error":        "Заявка попадает под действующие ограничения полётов",
+		"restrictions": restrictionErr.Hits,
+	})
+
+	return true
+}
+
 func generateHashString(s string) string {
 	h := sha1.New()
 	h.Write([]byte(s))
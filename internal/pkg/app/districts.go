@@ -0,0 +1,144 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"drones/internal/app/ds"
+	"drones/internal/app/geo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary      Округа
+// @Description  Возвращает округа с модераторами, числом действующих регионов и заявок, ждущих модерации
+// @Tags         Округа
+// @Produce      json
+// @Success      200  {array}  ds.DistrictSummary
+// @Router       /districts [get]
+func (a *Application) get_districts(c *gin.Context) {
+	districts, err := a.repo.GetDistricts()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, districts)
+}
+
+// @Summary      Округ
+// @Description  Возвращает округ с границей, модераторами и регионами
+// @Tags         Округа
+// @Produce      json
+// @Success      200  {object}  string
+// @Param district_id path int true "id округа"
+// @Router       /district/{district_id} [get]
+func (a *Application) get_district(c *gin.Context) {
+	district_id, err := strconv.Atoi(c.Param("district_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Передан некорректный ID округа")
+		return
+	}
+
+	district, err := a.repo.GetDistrict(district_id)
+	if err != nil {
+		c.String(flightErrorStatus(err), "Не могу найти округ")
+		return
+	}
+
+	moderators, err := a.repo.GetDistrictModerators(district_id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	regions, err := a.repo.GetDistrictRegions(district_id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"district":   district,
+		"moderators": moderators,
+		"regions":    regions,
+	})
+}
+
+// @Summary      Создать округ
+// @Description  Создаёт округ и назначает ему модераторов
+// @Tags         Округа
+// @Accept       json
+// @Produce      json
+// @Success      201  {object}  ds.District
+// @Failure      409  {object}  string "Округ с таким названием уже есть"
+// @Param request_body body ds.DistrictRequestBody true "Округ"
+// @Router       /districts [post]
+func (a *Application) add_district(c *gin.Context) {
+	var request_body ds.DistrictRequestBody
+	if err := c.BindJSON(&request_body); err != nil || request_body.Name == "" {
+		c.String(http.StatusBadRequest, "Нужно передать хотя бы название округа")
+		return
+	}
+
+	district := ds.District{Name: request_body.Name, Boundary: []byte(request_body.Boundary)}
+
+	err := a.repo.CreateDistrict(&district, request_body.Moderators)
+	if respondDistrictError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, district)
+}
+
+// @Summary      Отредактировать округ
+// @Description  Меняет название, границу или модераторов округа; непереданные поля не меняются
+// @Tags         Округа
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  string
+// @Param district_id path int true "id округа"
+// @Param request_body body ds.DistrictRequestBody true "Новые данные округа"
+// @Failure      409  {object}  string "Округ с таким названием уже есть"
+// @Router       /district/{district_id} [put]
+func (a *Application) edit_district(c *gin.Context) {
+	district_id, err := strconv.Atoi(c.Param("district_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Передан некорректный ID округа")
+		return
+	}
+
+	var request_body ds.DistrictRequestBody
+	if err := c.BindJSON(&request_body); err != nil {
+		c.String(http.StatusBadRequest, "Не могу распознать json")
+		return
+	}
+
+	district := ds.District{ID: uint(district_id), Name: request_body.Name, Boundary: []byte(request_body.Boundary)}
+
+	err = a.repo.EditDistrict(&district, request_body.Moderators)
+	if respondDistrictError(c, err) {
+		return
+	}
+
+	c.String(http.StatusOK, "Округ обновлён")
+}
+
+func respondDistrictError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, geo.ErrInvalidGeometry):
+		c.String(http.StatusBadRequest, "Некорректная граница округа\n"+err.Error())
+	case errors.Is(err, ds.ErrNotModerator):
+		c.String(http.StatusBadRequest, "Отвечать за округ могут только модераторы\n"+err.Error())
+	case errors.Is(err, ds.ErrDistrictExists):
+		c.String(http.StatusConflict, "Округ с таким названием уже есть")
+	default:
+		c.Error(err)
+		c.String(flightErrorStatus(err), "Не могу сохранить округ\n"+err.Error())
+	}
+
+	return true
+}
@@ -0,0 +1,31 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", strconv.Quote(strconv.Itoa(version)))
+}
+
+// ifMatch достаёт из If-Match версию, которую клиент получил в ETag.
+// Если заголовка нет, он битый или это "*", отвечает сам и возвращает false: версия нужна конкретная.
+func ifMatch(c *gin.Context) (int, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		c.String(http.StatusPreconditionRequired, "Нужен заголовок If-Match с ETag, полученным вместе с данными")
+		return 0, false
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+	if err != nil || version < 1 {
+		c.String(http.StatusBadRequest, "Не могу распознать If-Match")
+		return 0, false
+	}
+
+	return version, true
+}
@@ -0,0 +1,172 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"drones/internal/app/ds"
+	"drones/internal/app/geo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rebuildRegionIndex перечитывает границы регионов после их изменения
+func (a *Application) rebuildRegionIndex() error {
+	shapes, err := a.repo.GetRegionShapes()
+	if err != nil {
+		return err
+	}
+
+	a.regions.Rebuild(shapes)
+
+	return nil
+}
+
+// regionIndex возвращает индекс, перестроив его, если границы поменяли в другом экземпляре сервиса.
+// Без redis отвечаем тем индексом, что есть.
+func (a *Application) regionIndex(ctx context.Context) *geo.RegionIndex {
+	version, err := a.redis.GetRegionsVersion(ctx)
+	if err != nil {
+		log.Println("can't get regions version:", err)
+		return a.regions
+	}
+
+	if version != a.regionsVersion.Load() {
+		if err := a.rebuildRegionIndex(); err != nil {
+			log.Println("can't rebuild region index:", err)
+			return a.regions
+		}
+		a.regionsVersion.Store(version)
+	}
+
+	return a.regions
+}
+
+// @Summary      Регионы в точке
+// @Description  Возвращает регионы, граница которых содержит точку
+// @Tags         Регионы
+// @Produce      json
+// @Success      200  {object}  string
+// @Param lat query number true "Широта"
+// @Param lon query number true "Долгота"
+// @Router       /regions/at [get]
+func (a *Application) get_regions_at(c *gin.Context) {
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lon, errLon := strconv.ParseFloat(c.Query("lon"), 64)
+	if errLat != nil || errLon != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		c.String(http.StatusBadRequest, "Нужны широта lat и долгота lon в градусах")
+		return
+	}
+
+	regions, err := a.repo.GetRegionsByIDs(a.regionIndex(c.Request.Context()).At(geo.Point{Lon: lon, Lat: lat}))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"regions": regions,
+	})
+}
+
+// @Summary      Регионы в рамке
+// @Description  Возвращает регионы, пересекающиеся с рамкой
+// @Tags         Регионы
+// @Produce      json
+// @Success      200  {object}  string
+// @Param bbox query string true "Рамка minLon,minLat,maxLon,maxLat"
+// @Router       /regions/within [get]
+func (a *Application) get_regions_within(c *gin.Context) {
+	box, ok := parseBBox(c.Query("bbox"))
+	if !ok {
+		c.String(http.StatusBadRequest, "Рамка bbox задаётся как minLon,minLat,maxLon,maxLat")
+		return
+	}
+
+	regions, err := a.repo.GetRegionsByIDs(a.regionIndex(c.Request.Context()).Within(box))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"regions": regions,
+	})
+}
+
+func parseBBox(value string) (geo.BBox, bool) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return geo.BBox{}, false
+	}
+
+	coordinates := [4]float64{}
+	for i, part := range parts {
+		coordinate, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return geo.BBox{}, false
+		}
+		coordinates[i] = coordinate
+	}
+
+	box := geo.BBox{MinLon: coordinates[0], MinLat: coordinates[1], MaxLon: coordinates[2], MaxLat: coordinates[3]}
+	if box.MinLon > box.MaxLon || box.MinLat > box.MaxLat {
+		return geo.BBox{}, false
+	}
+
+	return box, true
+}
+
+// afterRegionChange обновляет индекс и поднимает общую версию границ, чтобы его перестроили и другие экземпляры.
+// Регион к этому моменту уже сохранён, так что ошибки только логируем.
+func (a *Application) afterRegionChange(ctx context.Context) {
+	if err := a.rebuildRegionIndex(); err != nil {
+		log.Println("can't rebuild region index:", err)
+	}
+
+	version, err := a.redis.BumpRegionsVersion(ctx)
+	if err != nil {
+		log.Println("can't bump regions version:", err)
+		return
+	}
+	a.regionsVersion.Store(version)
+}
+
+// planRoute проверяет маршрут из заявки и находит регионы, которые задевает его коридор
+func (a *Application) planRoute(ctx context.Context, request ds.RouteRequest) (*ds.FlightRoute, error) {
+	if request.CorridorWidth < 0 || request.CorridorWidth > geo.MaxCorridorWidth {
+		return nil, fmt.Errorf("%w: corridor width must be between 0 and %d m", geo.ErrInvalidGeometry, geo.MaxCorridorWidth)
+	}
+
+	var line geo.LineString
+	var err error
+	if len(request.Path) > 0 {
+		line, err = geo.ParseRoute(request.Path)
+	} else {
+		points := []geo.Point{}
+		for _, waypoint := range request.Waypoints {
+			points = append(points, geo.Point{Lon: waypoint[0], Lat: waypoint[1]})
+		}
+		line, err = geo.NewRoute(points)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	region_ids := a.regionIndex(ctx).AlongRoute(line, request.CorridorWidth)
+	if len(region_ids) == 0 {
+		return nil, ds.ErrRouteOutsideRegions
+	}
+
+	path, err := json.Marshal(line)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ds.FlightRoute{Path: path, CorridorWidth: request.CorridorWidth, RegionIDs: region_ids}, nil
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"drones/internal/app/scheduler"
+)
+
+func (a *Application) jobs() []scheduler.Job {
+	return []scheduler.Job{
+		{Name: "reject_unmoderated_flights", Run: a.rejectUnmoderatedFlights},
+		{Name: "purge_stale_drafts", Run: a.purgeStaleDrafts},
+		{Name: "finish_flights", Run: a.finishFlights},
+		{Name: "detect_overruns", Run: a.detectOverruns},
+	}
+}
+
+func (a *Application) rejectUnmoderatedFlights(ctx context.Context) error {
+	count, err := a.repo.RejectUnmoderatedFlights(time.Now())
+	if count > 0 {
+		log.Printf("scheduler: rejected %d unmoderated flights", count)
+	}
+
+	return err
+}
+
+func (a *Application) purgeStaleDrafts(ctx context.Context) error {
+	if a.config.Scheduler.DraftMaxAge <= 0 {
+		return nil
+	}
+
+	count, err := a.repo.PurgeStaleDrafts(time.Now().Add(-a.config.Scheduler.DraftMaxAge))
+	if count > 0 {
+		log.Printf("scheduler: purged %d stale drafts", count)
+	}
+
+	return err
+}
+
+func (a *Application) finishFlights(ctx context.Context) error {
+	count, err := a.repo.FinishFlights(time.Now())
+	if count > 0 {
+		log.Printf("scheduler: finished %d flights", count)
+	}
+
+	return err
+}
+
+func (a *Application) detectOverruns(ctx context.Context) error {
+	count, err := a.repo.DetectOverruns(time.Now())
+	if count > 0 {
+		log.Printf("scheduler: %d flights overran their arrival time", count)
+	}
+
+	return err
+}
@@ -1,12 +1,17 @@
 package app
 
 import (
+	"bytes"
 	"drones/internal/app/ds"
 	"drones/internal/app/role"
+	"drones/internal/app/signature"
 	"errors"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
@@ -100,3 +105,59 @@ func (a *Application) WithAuthCheck(assignedRoles ...role.Role) func(context *gi
 	}
 
 }
+
+// WithCallbackSignature пропускает только запросы, подписанные общим секретом (см. пакет signature).
+// Подпись запоминается в Redis, так что перехваченный запрос нельзя отправить ещё раз.
+func (a *Application) WithCallbackSignature() func(context *gin.Context) {
+	return func(c *gin.Context) {
+		if a.config.Callback.Secret == "" {
+			c.AbortWithStatus(http.StatusServiceUnavailable)
+			log.Println("callback secret is not configured")
+			return
+		}
+
+		timestamp := c.GetHeader(signature.TimestampHeader)
+		requestSignature := c.GetHeader(signature.SignatureHeader)
+
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil || requestSignature == "" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		skew := time.Since(time.Unix(unix, 0))
+		if skew < 0 {
+			skew = -skew
+		}
+		if skew > a.config.Callback.MaxSkew {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		if !signature.Verify([]byte(a.config.Callback.Secret), timestamp, body, requestSignature) {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		// hex в заголовке можно записать в другом регистре, поэтому запоминаем каноничную подпись, а не присланную строку.
+		// Старше MaxSkew запрос не пройдёт и так, дольше помнить подпись незачем
+		nonce := signature.Sign([]byte(a.config.Callback.Secret), timestamp, body)
+		fresh, err := a.redis.RememberNonce(c.Request.Context(), nonce, 2*a.config.Callback.MaxSkew)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		if !fresh {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+	}
+}
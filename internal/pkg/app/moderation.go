@@ -0,0 +1,268 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"drones/internal/app/ds"
+	"drones/internal/app/role"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// decideFlight - общий путь решения модератора по заявке. Заявка должна быть закреплена за этим модератором;
+// если она свободна, она закрепляется на время решения, а после успешного решения захват снимается.
+func (a *Application) decideFlight(ctx context.Context, moderator uuid.UUID, moderatorRole role.Role, flight_id int, decision ds.ModerationDecision) ([]ds.FlightConflict, error) {
+	if err := a.validateDecision(decision); err != nil {
+		return nil, err
+	}
+
+	holder, ok, fresh, err := a.redis.AutoClaimFlight(ctx, flight_id, moderator, a.config.Moderation.ClaimTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, &ds.ClaimError{FlightID: flight_id, Holder: holder}
+	}
+
+	// захват, взятый только ради этого решения, не должен держать заявку до истечения срока и при ошибке
+	if fresh {
+		defer a.redis.ReleaseFlight(ctx, flight_id, moderator)
+	}
+
+	conflicts, err := a.repo.ModConfirmFlight(moderator, moderatorRole, flight_id, decision)
+	if err != nil {
+		return conflicts, err
+	}
+
+	// решение уже записано, незакрытый захват сам истечёт через ClaimTTL
+	if err := a.redis.ReleaseFlight(ctx, flight_id, moderator); err != nil {
+		log.Printf("can't release claim of flight %d: %v", flight_id, err)
+	}
+
+	return conflicts, nil
+}
+
+// validateDecision проверяет, что у отклонения есть код причины из конфига и пояснение
+func (a *Application) validateDecision(decision ds.ModerationDecision) error {
+	if decision.Confirm {
+		return nil
+	}
+
+	if _, ok := a.config.Moderation.RejectionReasons[decision.Code]; !ok || strings.TrimSpace(decision.Reason) == "" {
+		return ds.ErrRejectionReason
+	}
+
+	return nil
+}
+
+// @Summary      Причины отклонения
+// @Description  Возвращает коды причин отклонения заявки, из которых выбирает модератор
+// @Tags         Заявки
+// @Produce      json
+// @Success      200  {object}  map[string]string
+// @Router       /flight/rejection_reasons [get]
+func (a *Application) get_rejection_reasons(c *gin.Context) {
+	c.JSON(http.StatusOK, a.config.Moderation.RejectionReasons)
+}
+
+// respondClaimed отвечает 409 с именем модератора, если заявка закреплена за другим
+func (a *Application) respondClaimed(c *gin.Context, err error) bool {
+	var claimErr *ds.ClaimError
+	if !errors.As(err, &claimErr) {
+		return false
+	}
+
+	holder := claimErr.Holder.String()
+	if user, err := a.repo.GetUserByID(claimErr.Holder); err == nil {
+		holder = user.Name
+	}
+
+	c.JSON(http.StatusConflict, gin.H{
+		"error":      "Заявку уже рассматривает другой модератор",
+		"claimed_by": holder,
+	})
+
+	return true
+}
+
+// @Summary      Взять заявку на рассмотрение
+// @Description  Закрепляет заявку за модератором на время из конфига; пока захват действует, решения других модераторов отклоняются
+// @Tags         Заявки
+// @Produce      json
+// @Success      200  {object}  string
+// @Failure      403  {object}  string "Заявку рассматривают модераторы её округов"
+// @Failure      409  {object}  string "Заявку уже рассматривает другой модератор"
+// @Param flight_id path int true "id заявки"
+// @Router       /flight/{flight_id}/claim [put]
+func (a *Application) claim_flight(c *gin.Context) {
+	flight_id, err := strconv.Atoi(c.Param("flight_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Передан некорректный ID полёта")
+		return
+	}
+
+	status, err := a.repo.GetFlightStatus(flight_id)
+	if err != nil {
+		c.String(flightErrorStatus(err), "Не могу найти заявку")
+		return
+	}
+
+	if status != ds.Formed.String() {
+		c.String(http.StatusConflict, "Взять на рассмотрение можно только сформированную заявку")
+		return
+	}
+
+	_userUUID, _ := c.Get("userUUID")
+	_userRole, _ := c.Get("role")
+
+	userUUID := _userUUID.(uuid.UUID)
+	userRole := _userRole.(role.Role)
+
+	if err := a.repo.CheckDistrictModerator(flight_id, userUUID, userRole); err != nil {
+		c.String(flightErrorStatus(err), "Заявку рассматривают модераторы её округов")
+		return
+	}
+
+	holder, ok, err := a.redis.ClaimFlight(c.Request.Context(), flight_id, userUUID, a.config.Moderation.ClaimTTL)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Не получается закрепить заявку")
+		return
+	}
+
+	if !ok && a.respondClaimed(c, &ds.ClaimError{FlightID: flight_id, Holder: holder}) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Заявка закреплена за вами",
+		"expires_in": int(a.config.Moderation.ClaimTTL.Seconds()),
+	})
+}
+
+// @Summary      Отпустить заявку
+// @Description  Снимает захват заявки, если он принадлежит текущему модератору
+// @Tags         Заявки
+// @Produce      json
+// @Success      200  {object}  string
+// @Param flight_id path int true "id заявки"
+// @Router       /flight/{flight_id}/claim [delete]
+func (a *Application) release_flight(c *gin.Context) {
+	flight_id, err := strconv.Atoi(c.Param("flight_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Передан некорректный ID полёта")
+		return
+	}
+
+	_userUUID, _ := c.Get("userUUID")
+	userUUID := _userUUID.(uuid.UUID)
+
+	err = a.redis.ReleaseFlight(c.Request.Context(), flight_id, userUUID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Не получается снять захват заявки")
+		return
+	}
+
+	c.String(http.StatusOK, "Заявка освобождена")
+}
+
+// сколько заявок можно решить одним запросом
+const maxBatchDecisions = 100
+
+// @Summary      Пакетное решение модератора
+// @Description  Одобряет или отклоняет несколько заявок. Каждая заявка решается в своей транзакции так же, как в /flight/moderator_confirm,
+// @Description  поэтому ошибка по одной заявке не мешает остальным; в ответе результат по каждой.
+// @Description  Version обязателен: заявка без версии не решается (StatusCode 428), с устаревшей версией - 412.
+// @Tags         Заявки
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}  ds.FlightActionResult
+// @Param request_body body ds.BatchModerationRequestBody true "Решения по заявкам"
+// @Router       /flight/moderator_confirm/batch [put]
+func (a *Application) mod_confirm_flights(c *gin.Context) {
+	var requestBody ds.BatchModerationRequestBody
+	if err := c.BindJSON(&requestBody); err != nil {
+		c.String(http.StatusBadRequest, "Передан плохой json")
+		return
+	}
+
+	if len(requestBody.Decisions) == 0 || len(requestBody.Decisions) > maxBatchDecisions {
+		c.String(http.StatusBadRequest, fmt.Sprintf("За раз можно решить от 1 до %d заявок", maxBatchDecisions))
+		return
+	}
+
+	_userUUID, _ := c.Get("userUUID")
+	_userRole, _ := c.Get("role")
+	userUUID := _userUUID.(uuid.UUID)
+	userRole := _userRole.(role.Role)
+
+	results := []ds.FlightActionResult{}
+	for _, item := range requestBody.Decisions {
+		flight, err := a.repo.GetFlightByID(item.FlightID)
+		if err != nil {
+			results = append(results, ds.FlightActionResult{FlightID: uint(item.FlightID), Error: err.Error(), StatusCode: flightErrorStatus(err)})
+			continue
+		}
+
+		new_status := ds.Rejected
+		if item.Confirm {
+			new_status = ds.Completed
+		}
+
+		if item.Version < 1 {
+			results = append(results, newFlightActionResult(*flight, new_status, ds.ErrVersionRequired))
+			continue
+		}
+
+		conflicts, err := a.decideFlight(c.Request.Context(), userUUID, userRole, item.FlightID, ds.ModerationDecision{
+			Confirm: item.Confirm,
+			Code:    item.Code,
+			Reason:  item.Reason,
+			Comment: item.Comment,
+			Version: item.Version,
+		})
+
+		result := newFlightActionResult(*flight, new_status, err)
+		result.Conflicts = conflicts
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// flightClaimants возвращает имена модераторов, рассматривающих заявки
+func (a *Application) flightClaimants(ctx context.Context, flights []ds.Flight) map[uint]string {
+	flight_ids := make([]int, 0, len(flights))
+	for _, flight := range flights {
+		if flight.Status == ds.Formed.String() {
+			flight_ids = append(flight_ids, int(flight.ID))
+		}
+	}
+
+	claims, err := a.redis.GetFlightClaims(ctx, flight_ids)
+	if err != nil {
+		return map[uint]string{}
+	}
+
+	names := map[uuid.UUID]string{}
+	claimants := map[uint]string{}
+	for flight_id, holder := range claims {
+		name, ok := names[holder]
+		if !ok {
+			if user, err := a.repo.GetUserByID(holder); err == nil {
+				name = user.Name
+			}
+			names[holder] = name
+		}
+		claimants[uint(flight_id)] = name
+	}
+
+	return claimants
+}
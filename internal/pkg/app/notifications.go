@@ -0,0 +1,54 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// @Summary      Получить уведомления
+// @Description  Возвращает уведомления текущего пользователя, новые сверху
+// @Tags         Уведомления
+// @Produce      json
+// @Success      200  {array}  ds.Notification
+// @Param unread query bool false "Только непрочитанные"
+// @Router       /notifications [get]
+func (a *Application) get_notifications(c *gin.Context) {
+	_userUUID, _ := c.Get("userUUID")
+	userUUID := _userUUID.(uuid.UUID)
+
+	notifications, err := a.repo.GetNotifications(userUUID, c.Query("unread") == "true")
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Не могу получить уведомления")
+		return
+	}
+
+	c.JSON(http.StatusOK, notifications)
+}
+
+// @Summary      Отметить уведомление прочитанным
+// @Tags         Уведомления
+// @Produce      json
+// @Success      200  {object}  string
+// @Param notification_id path int true "id уведомления"
+// @Router       /notifications/{notification_id}/read [put]
+func (a *Application) read_notification(c *gin.Context) {
+	notification_id, err := strconv.Atoi(c.Param("notification_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Передан некорректный ID уведомления")
+		return
+	}
+
+	_userUUID, _ := c.Get("userUUID")
+	userUUID := _userUUID.(uuid.UUID)
+
+	err = a.repo.MarkNotificationRead(notification_id, userUUID)
+	if err != nil {
+		c.String(flightErrorStatus(err), "Не могу найти уведомление")
+		return
+	}
+
+	c.String(http.StatusOK, "Уведомление прочитано")
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary      Неотправленные сообщения
+// @Description  Сообщения во внешние сервисы, для которых кончились попытки отправки
+// @Tags         Служебное
+// @Produce      json
+// @Success      200  {array}  ds.OutboxMessage
+// @Router       /outbox/dead [get]
+func (a *Application) get_dead_letters(c *gin.Context) {
+	messages, err := a.repo.GetDeadLetters()
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Не могу получить неотправленные сообщения")
+		return
+	}
+
+	c.JSON(http.StatusOK, messages)
+}
+
+// @Summary      Повторить отправку
+// @Description  Возвращает сообщение из dead letter в очередь с обнулённым счётчиком попыток
+// @Tags         Служебное
+// @Produce      json
+// @Success      200  {object}  string
+// @Param message_id path int true "id сообщения"
+// @Router       /outbox/{message_id}/retry [put]
+func (a *Application) retry_outbox_message(c *gin.Context) {
+	message_id, err := strconv.Atoi(c.Param("message_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Передан некорректный ID сообщения")
+		return
+	}
+
+	err = a.repo.RequeueOutboxMessage(message_id)
+	if err != nil {
+		c.String(flightErrorStatus(err), "Не могу вернуть сообщение в очередь")
+		return
+	}
+
+	c.String(http.StatusOK, "Сообщение снова в очереди")
+}
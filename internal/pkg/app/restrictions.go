@@ -0,0 +1,117 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"drones/internal/app/ds"
+	"drones/internal/app/geo"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// @Summary      Ограничения полётов
+// @Description  Возвращает временные ограничения полётов с регионами, на которые они действуют
+// @Tags         Ограничения
+// @Produce      json
+// @Success      200  {array}  ds.Restriction
+// @Param active query bool false "Только неснятые и ещё не закончившиеся"
+// @Router       /restrictions [get]
+func (a *Application) get_restrictions(c *gin.Context) {
+	restrictions, err := a.repo.GetRestrictions(c.Query("active") == "true")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, restrictions)
+}
+
+// @Summary      Ввести ограничение полётов
+// @Description  Создаёт временное ограничение над областью и/или регионами и возвращает уже одобренные заявки, которые оно задевает
+// @Tags         Ограничения
+// @Accept       json
+// @Produce      json
+// @Success      201  {object}  string
+// @Param request_body body ds.CreateRestrictionRequestBody true "Ограничение"
+// @Router       /restrictions [post]
+func (a *Application) add_restriction(c *gin.Context) {
+	var request_body ds.CreateRestrictionRequestBody
+	if err := c.BindJSON(&request_body); err != nil {
+		c.String(http.StatusBadRequest, "Не могу распознать json")
+		return
+	}
+
+	starts_at, errStart := time.Parse(time.RFC3339, request_body.StartsAt)
+	ends_at, errEnd := time.Parse(time.RFC3339, request_body.EndsAt)
+	if errStart != nil || errEnd != nil {
+		c.String(http.StatusBadRequest, "Начало и конец ограничения нужно передать в RFC3339")
+		return
+	}
+
+	_userUUID, _ := c.Get("userUUID")
+	userUUID := _userUUID.(uuid.UUID)
+
+	restriction := ds.Restriction{
+		Reason:       request_body.Reason,
+		StartsAt:     starts_at,
+		EndsAt:       ends_at,
+		MinAltitude:  request_body.MinAltitude,
+		MaxAltitude:  request_body.MaxAltitude,
+		Blocking:     request_body.Blocking == nil || *request_body.Blocking,
+		CreatorRefer: &userUUID,
+		DateCreated:  time.Now(),
+	}
+
+	area_region_ids := []int{}
+	if len(request_body.Boundary) > 0 {
+		area, err := geo.ParseBoundary(request_body.Boundary)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Некорректная область ограничения\n"+err.Error())
+			return
+		}
+		restriction.Boundary = []byte(request_body.Boundary)
+		area_region_ids = a.regionIndex(c.Request.Context()).Intersecting(area)
+	}
+
+	if err := ds.ValidateRestriction(restriction, len(restriction.Boundary) > 0 || len(request_body.Regions) > 0); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	conflicts, err := a.repo.CreateRestriction(&restriction, request_body.Regions, area_region_ids)
+	if err != nil {
+		c.Error(err)
+		c.String(flightErrorStatus(err), "Не могу ввести ограничение\n"+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"restriction": restriction,
+		"conflicts":   conflicts,
+	})
+}
+
+// @Summary      Снять ограничение полётов
+// @Description  Снимает ограничение досрочно
+// @Tags         Ограничения
+// @Produce      json
+// @Success      200  {object}  string
+// @Param restriction_id path int true "id ограничения"
+// @Router       /restriction/{restriction_id}/lift [put]
+func (a *Application) lift_restriction(c *gin.Context) {
+	restriction_id, err := strconv.Atoi(c.Param("restriction_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Передан некорректный ID ограничения")
+		return
+	}
+
+	if err := a.repo.LiftRestriction(restriction_id); err != nil {
+		c.String(flightErrorStatus(err), "Не могу снять ограничение")
+		return
+	}
+
+	c.String(http.StatusOK, "Ограничение снято")
+}
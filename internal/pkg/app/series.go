@@ -0,0 +1,170 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+
+	"drones/internal/app/ds"
+	"drones/internal/app/role"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type getFlightSeriesResp struct {
+	Series  ds.FlightSeries
+	Flights []ds.FlightNoUser
+}
+
+// @Summary      Получить серию полётов
+// @Description  Возвращает правило повторения и все полёты, порождённые регулярной заявкой
+// @Tags         Заявки
+// @Produce      json
+// @Success      200  {object}  getFlightSeriesResp
+// @Param series_id path int true "id серии"
+// @Router       /flight/series/{series_id} [get]
+func (a *Application) get_flight_series(c *gin.Context) {
+	series_id, err := strconv.Atoi(c.Param("series_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Передан некорректный ID серии")
+		return
+	}
+
+	_userUUID, _ := c.Get("userUUID")
+	_userRole, _ := c.Get("role")
+	userUUID := _userUUID.(uuid.UUID)
+	userRole := _userRole.(role.Role)
+
+	series, err := a.repo.GetSeries(series_id)
+	if err != nil {
+		c.String(flightErrorStatus(err), "Не могу найти серию полётов")
+		return
+	}
+
+	if userRole == role.User && *series.UserRefer != userUUID {
+		c.String(http.StatusForbidden, "Это не ваша серия полётов")
+		return
+	}
+
+	flights, err := a.repo.GetSeriesFlights(series_id)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Не могу получить полёты серии")
+		return
+	}
+
+	clean_flights := []ds.FlightNoUser{}
+	for _, flight := range flights {
+		clean_flights = append(clean_flights, flightNoUser(flight))
+	}
+
+	c.JSON(http.StatusOK, getFlightSeriesResp{
+		Series:  *series,
+		Flights: clean_flights,
+	})
+}
+
+// @Summary      Решение по серии полётов
+// @Description  Одобряет или отклоняет все будущие сформированные полёты серии; в Overrides можно задать другое решение для отдельных полётов
+// @Description  Для каждого полёта в Versions нужна версия из его ETag: без неё полёт не решается (428), с устаревшей - 412
+// @Tags         Заявки
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}  ds.FlightActionResult
+// @Param series_id path int true "id серии"
+// @Param request_body body ds.SeriesDecisionRequestBody true "Решение"
+// @Router       /flight/series/{series_id}/moderator_confirm [put]
+func (a *Application) mod_confirm_flight_series(c *gin.Context) {
+	series_id, err := strconv.Atoi(c.Param("series_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Передан некорректный ID серии")
+		return
+	}
+
+	var requestBody ds.SeriesDecisionRequestBody
+	if err := c.BindJSON(&requestBody); err != nil {
+		c.String(http.StatusBadRequest, "Передан плохой json")
+		return
+	}
+
+	_userUUID, _ := c.Get("userUUID")
+	_userRole, _ := c.Get("role")
+	userUUID := _userUUID.(uuid.UUID)
+	userRole := _userRole.(role.Role)
+
+	flights, err := a.repo.FutureSeriesFlights(series_id)
+	if err != nil {
+		c.String(flightErrorStatus(err), "Не получается обработать серию полётов\n"+err.Error())
+		return
+	}
+
+	// каждый полёт решается отдельно, так что один неудачный не откатывает остальные
+	results := []ds.FlightActionResult{}
+	for _, flight := range flights {
+		if flight.Status != ds.Formed.String() {
+			continue
+		}
+
+		confirm := requestBody.Confirm
+		if override, ok := requestBody.Overrides[flight.ID]; ok {
+			confirm = override
+		}
+
+		new_status := ds.Rejected
+		if confirm {
+			new_status = ds.Completed
+		}
+
+		version, ok := requestBody.Versions[flight.ID]
+		if !ok || version < 1 {
+			results = append(results, newFlightActionResult(flight, new_status, ds.ErrVersionRequired))
+			continue
+		}
+
+		_, err := a.decideFlight(c.Request.Context(), userUUID, userRole, int(flight.ID), ds.ModerationDecision{
+			Confirm: confirm,
+			Code:    requestBody.Code,
+			Reason:  requestBody.Reason,
+			Comment: requestBody.Comment,
+			Version: version,
+		})
+		results = append(results, newFlightActionResult(flight, new_status, err))
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// @Summary      Отменить серию полётов
+// @Description  Удаляет все будущие полёты серии, кроме перечисленных в Keep
+// @Tags         Заявки
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}  ds.FlightActionResult
+// @Param series_id path int true "id серии"
+// @Param request_body body ds.SeriesCancelRequestBody true "Причина и исключения"
+// @Router       /flight/series/{series_id}/cancel [put]
+func (a *Application) cancel_flight_series(c *gin.Context) {
+	series_id, err := strconv.Atoi(c.Param("series_id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Передан некорректный ID серии")
+		return
+	}
+
+	var requestBody ds.SeriesCancelRequestBody
+	if err := c.BindJSON(&requestBody); err != nil {
+		c.String(http.StatusBadRequest, "Передан плохой json")
+		return
+	}
+
+	_userUUID, _ := c.Get("userUUID")
+	_userRole, _ := c.Get("role")
+	userUUID := _userUUID.(uuid.UUID)
+	userRole := _userRole.(role.Role)
+
+	results, err := a.repo.CancelSeries(userUUID, userRole, series_id, requestBody)
+	if err != nil {
+		c.String(flightErrorStatus(err), "Не получается отменить серию полётов\n"+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}